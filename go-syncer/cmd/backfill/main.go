@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/github"
+	"github.com/ilaif/athena-cycle/syncer/internal/jobs"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+// backfill queues backfill jobs for repositories of a GitHub source: backfill <source> <owner/repo>...
+func main() {
+	log, err := logging.NewLogger()
+	if err != nil {
+		panic(err)
+	}
+
+	if len(os.Args) < 3 {
+		log.Info("Usage: backfill <source> <owner/repo>...")
+		os.Exit(2)
+	}
+	source, repos := os.Args[1], lo.Map(os.Args[2:], func(r string, _ int) config.GitHubRepository {
+		return config.GitHubRepository(r)
+	})
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Error(err, "Failed to load config")
+		os.Exit(1)
+	}
+	if !lo.ContainsBy(cfg.GitHubSources(), func(s *config.GitHubSource) bool { return s.Name == source && s.Enabled() }) {
+		log.Info("Unknown or disabled GitHub source", "source", source)
+		os.Exit(2)
+	}
+
+	db, err := sqlx.Connect("pgx", cfg.PgURL)
+	if err != nil {
+		log.Error(err, "Failed to connect to database")
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx := logging.NewContext(context.Background(), log)
+	queue := jobs.NewQueue(db)
+	for _, repo := range repos {
+		repoLog := log.WithValues("source", source, "repo", repo)
+		if !repo.Valid() {
+			repoLog.Info("Invalid repository, expected owner/repo")
+			continue
+		}
+		if err := github.EnqueueBackfill(ctx, queue, source, repo); err != nil {
+			repoLog.Error(err, "Failed to queue backfill")
+			continue
+		}
+		repoLog.Info("Queued backfill")
+	}
+}
@@ -2,19 +2,35 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/go-logr/logr"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
+	"github.com/samber/lo"
 
+	"github.com/ilaif/athena-cycle/syncer/internal/bitbucket"
 	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/dora"
 	"github.com/ilaif/athena-cycle/syncer/internal/github"
+	"github.com/ilaif/athena-cycle/syncer/internal/gitlab"
+	"github.com/ilaif/athena-cycle/syncer/internal/health"
+	"github.com/ilaif/athena-cycle/syncer/internal/jira"
+	"github.com/ilaif/athena-cycle/syncer/internal/jobs"
 	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+	"github.com/ilaif/athena-cycle/syncer/internal/sources"
+)
+
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpShutdownTimeout   = 10 * time.Second
 )
 
 func main() {
@@ -56,35 +72,127 @@ func run() error {
 		return errors.Wrap(err, "failed to ping database")
 	}
 
+	queue := jobs.NewQueue(pgClient)
+	registry := sources.NewRegistry(pgClient)
+	githubSources, err := registerSources(registry, cfg, queue)
+	if err != nil {
+		return err
+	}
+
 	c := cron.New(
 		cron.WithLogger(log),
 		cron.WithChain(
 			cron.Recover(log),
 		),
 	)
-	// Initial sync
-	if err := sync(ctx, pgClient, cfg); err != nil {
-		return errors.Wrap(err, "failed to sync")
+	tokenPools := lo.Map(githubSources, func(s *github.Source, _ int) health.TokenPool { return s })
+	checker := health.NewChecker(pgClient, c, registry, tokenPools, cfg.ReadyMaxSyncAge, cfg.LiveMaxSyncDuration)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", checker.Healthz(ctx))
+	mux.Handle("/readyz", checker.Readyz(ctx))
+	for _, s := range githubSources {
+		if !s.WebhooksEnabled() {
+			log.Info("GitHub webhook secret not set, webhooks are disabled", "source", s.Name())
+			continue
+		}
+		mux.Handle(s.WebhookPath(), s.WebhookHandler(ctx, pgClient))
+	}
+	srv := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
 	}
-	if _, err := c.AddFunc("@every 10m", func() {
-		if err := sync(ctx, pgClient, cfg); err != nil {
-			log.Error(err, "Failed to sync")
+	go func() {
+		log.Info("Starting HTTP server", "addr", cfg.HTTPAddr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err, "HTTP server failed")
+			cancel()
+		}
+	}()
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer shutdownCancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "Failed to shut down HTTP server")
 		}
-	}); err != nil {
-		return errors.Wrap(err, "failed to add sync job to cron")
+	}()
+
+	worker := jobs.NewWorker(queue, cfg.JobWorkers)
+	github.RegisterJobHandlers(worker, pgClient, githubSources)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		worker.Run(ctx)
+	}()
+
+	// Initial sync
+	registry.SyncAll(ctx)
+	if err := registry.Schedule(ctx, c); err != nil {
+		return errors.Wrap(err, "failed to schedule sources")
 	}
 	c.Start()
+	checker.CronStarted()
 
 	<-ctx.Done()
 	c.Stop()
+	<-workerDone
 
 	log.Info("Syncer has shut down gracefully")
 	return nil
 }
 
-func sync(ctx context.Context, db *sqlx.DB, cfg *config.Config) error {
-	if err := github.Sync(ctx, db, cfg.GitHubRepositories, cfg.GitHubTokens); err != nil {
-		return errors.Wrap(err, "failed to sync repositories")
+// registerSources registers the sources enabled in the config, and returns the GitHub sources to serve webhooks for.
+func registerSources(registry *sources.Registry, cfg *config.Config, queue *jobs.Queue) ([]*github.Source, error) {
+	githubSources := []*github.Source{}
+	syncDeployments := false
+	for _, source := range cfg.GitHubSources() {
+		if !source.Enabled() {
+			continue
+		}
+		s, err := github.NewSource(source, queue)
+		if err != nil {
+			return nil, err
+		}
+		githubSources = append(githubSources, s)
+		syncDeployments = syncDeployments || source.SyncDeployments
 	}
-	return nil
+
+	toRegister := lo.Map(githubSources, func(s *github.Source, _ int) sources.Source { return s })
+	if cfg.GitLab.Enabled() {
+		s, err := gitlab.NewSource(&cfg.GitLab)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create GitLab source")
+		}
+		toRegister = append(toRegister, s)
+	}
+	if cfg.Bitbucket.Enabled() {
+		s, err := bitbucket.NewSource(&cfg.Bitbucket)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Bitbucket source")
+		}
+		toRegister = append(toRegister, s)
+	}
+	if syncDeployments {
+		toRegister = append(toRegister, dora.NewSource(cfg.DORASchedule))
+	}
+	if cfg.Jira.Enabled() {
+		s, err := jira.NewSource(&cfg.Jira)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Jira source")
+		}
+		toRegister = append(toRegister, s)
+	}
+	// Issues may be synced by the Python syncer, so pull requests are linked even when the Jira sync is disabled
+	if cfg.Jira.LinkingEnabled() {
+		toRegister = append(toRegister, jira.NewLinkSource(&cfg.Jira, cfg.Jira.LinkSchedule))
+	}
+
+	for _, s := range toRegister {
+		if err := registry.Register(s); err != nil {
+			return nil, err
+		}
+	}
+	return githubSources, nil
 }
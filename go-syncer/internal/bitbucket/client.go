@@ -0,0 +1,68 @@
+package bitbucket
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/utils/transport"
+)
+
+// Client is a minimal client of the Bitbucket Cloud or Bitbucket Server REST API.
+// With a username, the token is an app password (Cloud) or a personal access token used with basic auth (Server),
+// otherwise it's sent as a bearer token.
+type Client struct {
+	api        api
+	host       string
+	username   string
+	token      string
+	httpClient *http.Client
+}
+
+func NewClient(source *config.BitbucketSource) (*Client, error) {
+	baseURL, err := url.Parse(source.BaseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Bitbucket base URL")
+	}
+	roundTripper, err := transport.New(source.CABundlePath)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		host:       baseURL.Hostname(),
+		username:   source.Username,
+		token:      source.Token,
+		httpClient: &http.Client{Transport: roundTripper},
+	}
+	if source.Server() {
+		c.api = &serverAPI{client: c, baseURL: baseURL}
+	} else {
+		c.host = cloudHost
+		c.api = &cloudAPI{client: c, baseURL: baseURL}
+	}
+	return c, nil
+}
+
+// get decodes the response of a GET request into out. Rate limited requests are retried after a delay.
+func (c *Client) get(ctx context.Context, u *url.URL, out interface{}) error {
+	log := logging.MustFromContext(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	log.V(1).Info("Requesting Bitbucket API", "path", u.Path)
+	_, err = transport.GetJSON(ctx, c.httpClient, req, out)
+	return err
+}
@@ -0,0 +1,231 @@
+package bitbucket
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const cloudPageLen = 50
+
+type cloudPage[T any] struct {
+	Values []*T    `json:"values"`
+	Next   *string `json:"next"`
+}
+
+type cloudUser struct {
+	DisplayName string `json:"display_name"`
+	Nickname    string `json:"nickname"`
+	AccountID   string `json:"account_id"`
+}
+
+type cloudPullRequest struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description *string   `json:"description"`
+	State       string    `json:"state"`
+	Draft       bool      `json:"draft"`
+	Author      cloudUser `json:"author"`
+	CreatedOn   time.Time `json:"created_on"`
+	UpdatedOn   time.Time `json:"updated_on"`
+	Source      struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+		Commit struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+	} `json:"source"`
+	MergeCommit *struct {
+		Hash string `json:"hash"`
+	} `json:"merge_commit"`
+}
+
+type cloudComment struct {
+	ID      int64 `json:"id"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	User      cloudUser `json:"user"`
+	Deleted   bool      `json:"deleted"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+type cloudReviewActivity struct {
+	Date time.Time `json:"date"`
+	User cloudUser `json:"user"`
+}
+
+// cloudActivity is an entry of a pull request's activity, only one of its fields is set.
+type cloudActivity struct {
+	Update *struct {
+		State  string    `json:"state"`
+		Date   time.Time `json:"date"`
+		Author cloudUser `json:"author"`
+	} `json:"update"`
+	Approval         *cloudReviewActivity `json:"approval"`
+	ChangesRequested *cloudReviewActivity `json:"changes_requested"`
+	Comment          *cloudComment        `json:"comment"`
+}
+
+type cloudCommit struct {
+	Hash string    `json:"hash"`
+	Date time.Time `json:"date"`
+}
+
+type cloudBuildStatus struct {
+	State     string    `json:"state"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+// cloudAPI is the Bitbucket Cloud API (2.0), where repositories are identified as workspace/repo_slug.
+type cloudAPI struct {
+	client  *Client
+	baseURL *url.URL
+}
+
+func (a *cloudAPI) repoURL(repo string, elem ...string) *url.URL {
+	return a.baseURL.JoinPath(append([]string{"repositories", repo}, elem...)...)
+}
+
+func (a *cloudAPI) listPullRequests(ctx context.Context, repo string, cursor string) ([]*pullRequest, string, error) {
+	u := a.repoURL(repo, "pullrequests")
+	u.RawQuery = url.Values{
+		"state":   {"OPEN", "MERGED", "DECLINED", "SUPERSEDED"},
+		"sort":    {"-updated_on"},
+		"pagelen": {strconv.Itoa(cloudPageLen)},
+	}.Encode()
+	if cursor != "" {
+		var err error
+		if u, err = url.Parse(cursor); err != nil {
+			return nil, "", errors.Wrap(err, "failed to parse next page URL")
+		}
+	}
+
+	var page cloudPage[cloudPullRequest]
+	if err := a.client.get(ctx, u, &page); err != nil {
+		return nil, "", errors.Wrap(err, "failed to list pull requests")
+	}
+	prs := lo.Map(page.Values, func(p *cloudPullRequest, _ int) *pullRequest {
+		pr := newPullRequest(a.client.host, repo, p.ID)
+		pr.Username = cloudUsername(p.Author)
+		pr.Title = p.Title
+		pr.Body = p.Description
+		pr.State = stateOf(p.State)
+		pr.Draft = p.Draft
+		pr.CreatedAt = p.CreatedOn
+		pr.UpdatedAt = p.UpdatedOn
+		pr.HeadSHA = p.Source.Commit.Hash
+		pr.HeadRef = p.Source.Branch.Name
+		if p.MergeCommit != nil && p.State == "MERGED" {
+			pr.MergeCommitSHA = &p.MergeCommit.Hash
+		}
+		pr.Data = p
+		return pr
+	})
+	return prs, lo.FromPtr(page.Next), nil
+}
+
+func (a *cloudAPI) getTimeline(ctx context.Context, pr *pullRequest) (*timeline, error) {
+	activities, err := cloudListAll[cloudActivity](ctx, a.client, a.repoURL(pr.Repo, "pullrequests", strconv.Itoa(pr.Number), "activity"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list activity")
+	}
+	t := &timeline{reviews: []*pullRequestReview{}, comments: []*pullRequestComment{}, events: []*pullRequestEvent{}}
+	for _, activity := range activities {
+		a.addActivity(pr, t, activity)
+	}
+
+	commits, err := cloudListAll[cloudCommit](ctx, a.client, a.repoURL(pr.Repo, "pullrequests", strconv.Itoa(pr.Number), "commits"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list commits")
+	}
+	pr.FirstCommitAt = earliest(lo.Map(commits, func(c *cloudCommit, _ int) time.Time { return c.Date }))
+
+	if pr.HeadSHA != "" {
+		statuses, err := cloudListAll[cloudBuildStatus](ctx, a.client, a.repoURL(pr.Repo, "commit", pr.HeadSHA, "statuses"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list build statuses")
+		}
+		setPullRequestCI(pr, lo.Map(statuses, func(s *cloudBuildStatus, _ int) *buildStatus {
+			return &buildStatus{state: s.State, createdAt: s.CreatedOn, updatedAt: s.UpdatedOn}
+		}))
+	}
+	return t, nil
+}
+
+// addActivity adds an activity entry to the timeline. Approvals and change requests have no ID, so their review ID is
+// derived from the pull request, user and time.
+func (a *cloudAPI) addActivity(pr *pullRequest, t *timeline, activity *cloudActivity) {
+	addReview := func(review *cloudReviewActivity, state, event string) {
+		username := cloudUsername(review.User)
+		id := syntheticID(pr.PrID, state, review.User.AccountID, review.Date.Unix())
+		t.reviews = append(t.reviews, newPullRequestReview(pr, id, username, state, review.Date, activity))
+		t.events = append(t.events, newPullRequestEvent(pr, event, username, review.Date, activity))
+	}
+	switch {
+	case activity.Approval != nil:
+		addReview(activity.Approval, reviewStateApproved, "approved")
+	case activity.ChangesRequested != nil:
+		addReview(activity.ChangesRequested, reviewStateChangesRequested, "changes_requested")
+	case activity.Comment != nil && !activity.Comment.Deleted:
+		c := activity.Comment
+		t.comments = append(t.comments, &pullRequestComment{
+			Host:      pr.Host,
+			Provider:  provider,
+			CommentID: c.ID,
+			PrID:      pr.PrID,
+			Repo:      pr.Repo,
+			Username:  cloudUsername(c.User),
+			Body:      c.Content.Raw,
+			CreatedAt: c.CreatedOn,
+			UpdatedAt: c.UpdatedOn,
+			Data:      c,
+		})
+	case activity.Update != nil:
+		u := activity.Update
+		event := "updated"
+		switch u.State {
+		case "MERGED":
+			event = "merged"
+			pr.MergedAt = lo.ToPtr(u.Date)
+		case "DECLINED", "SUPERSEDED":
+			event = strings.ToLower(u.State)
+		}
+		t.events = append(t.events, newPullRequestEvent(pr, event, cloudUsername(u.Author), u.Date, activity))
+	}
+}
+
+func cloudListAll[T any](ctx context.Context, client *Client, u *url.URL) ([]*T, error) {
+	u.RawQuery = url.Values{"pagelen": {strconv.Itoa(cloudPageLen)}}.Encode()
+	entities := []*T{}
+	for {
+		var page cloudPage[T]
+		if err := client.get(ctx, u, &page); err != nil {
+			return nil, err
+		}
+		entities = append(entities, page.Values...)
+		if page.Next == nil {
+			return entities, nil
+		}
+		next, err := url.Parse(*page.Next)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse next page URL")
+		}
+		u = next
+	}
+}
+
+func cloudUsername(user cloudUser) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.DisplayName
+}
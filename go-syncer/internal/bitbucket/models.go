@@ -0,0 +1,150 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+)
+
+const (
+	provider  = "bitbucket"
+	cloudHost = "bitbucket.org"
+
+	reviewStateApproved         = "APPROVED"
+	reviewStateDismissed        = "DISMISSED"
+	reviewStateChangesRequested = "CHANGES_REQUESTED"
+
+	ciStateSuccess = "success"
+	ciStateFailure = "failure"
+	ciStatePending = "pending"
+)
+
+// api is a flavor of the Bitbucket API, Cloud and Server differ in every endpoint but sync the same rows.
+type api interface {
+	// listPullRequests lists a page of the pull requests of a repository, most recently updated first.
+	// The returned cursor is empty on the last page.
+	listPullRequests(ctx context.Context, repo string, cursor string) ([]*pullRequest, string, error)
+	// getTimeline fills in the commit and build fields of a pull request and returns its reviews, comments and events.
+	getTimeline(ctx context.Context, pr *pullRequest) (*timeline, error)
+}
+
+type timeline struct {
+	reviews  []*pullRequestReview
+	comments []*pullRequestComment
+	events   []*pullRequestEvent
+}
+
+// The pull request rows are shared by every provider.
+type (
+	pullRequest        = pg.PullRequest
+	pullRequestReview  = pg.PullRequestReview
+	pullRequestComment = pg.PullRequestComment
+)
+
+type pullRequestEvent struct {
+	Host      string      `db:"host"`
+	Provider  string      `db:"provider"`
+	EventID   *int64      `db:"event_id"`
+	PrID      int64       `db:"pr_id"`
+	Repo      string      `db:"repo"`
+	Event     string      `db:"event"`
+	Actor     string      `db:"actor"`
+	CreatedAt time.Time   `db:"created_at"`
+	CommitID  *string     `db:"commit_id"`
+	Data      interface{} `db:"data"`
+}
+
+// buildStatus is a build reported on the head commit of a pull request.
+type buildStatus struct {
+	state     string // SUCCESSFUL, FAILED, INPROGRESS or STOPPED
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// syntheticID derives a stable ID for entities Bitbucket only numbers within a repository, such as pull requests.
+func syntheticID(parts ...interface{}) int64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprint(h, parts...)
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+func newPullRequest(host, repo string, number int) *pullRequest {
+	return &pullRequest{
+		Host:     host,
+		Provider: provider,
+		PrID:     syntheticID(host, "/", repo, "#", number),
+		Repo:     repo,
+		Number:   number,
+	}
+}
+
+func newPullRequestReview(pr *pullRequest, id int64, username, state string, submittedAt time.Time, data interface{},
+) *pullRequestReview {
+	return &pullRequestReview{
+		Host:        pr.Host,
+		Provider:    provider,
+		ReviewID:    id,
+		PrID:        pr.PrID,
+		Repo:        pr.Repo,
+		Username:    username,
+		State:       state,
+		SubmittedAt: submittedAt,
+		Data:        data,
+	}
+}
+
+func newPullRequestEvent(pr *pullRequest, event, actor string, createdAt time.Time, data interface{}) *pullRequestEvent {
+	return &pullRequestEvent{
+		Host:      pr.Host,
+		Provider:  provider,
+		PrID:      pr.PrID,
+		Repo:      pr.Repo,
+		Event:     event,
+		Actor:     actor,
+		CreatedAt: createdAt,
+		Data:      data,
+	}
+}
+
+// setPullRequestCI rolls up the builds of the head commit, the first build approximates the time of the push.
+func setPullRequestCI(pr *pullRequest, statuses []*buildStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+	state := ciStateSuccess
+	for _, s := range statuses {
+		switch s.state {
+		case "FAILED", "STOPPED":
+			state = ciStateFailure
+		case "INPROGRESS":
+			if state != ciStateFailure {
+				state = ciStatePending
+			}
+		}
+	}
+	pr.CIState = &state
+	pr.CIStartedAt = lo.ToPtr(lo.MinBy(statuses, func(a, b *buildStatus) bool { return a.createdAt.Before(b.createdAt) }).createdAt)
+	if state != ciStatePending {
+		pr.CICompletedAt = lo.ToPtr(lo.MaxBy(statuses, func(a, b *buildStatus) bool { return a.updatedAt.After(b.updatedAt) }).updatedAt)
+	}
+}
+
+func earliest(times []time.Time) *time.Time {
+	if len(times) == 0 {
+		return nil
+	}
+	return lo.ToPtr(lo.MinBy(times, func(a, b time.Time) bool { return a.Before(b) }))
+}
+
+func stateOf(bitbucketState string) string {
+	if bitbucketState == "OPEN" {
+		return "open"
+	}
+	return "closed"
+}
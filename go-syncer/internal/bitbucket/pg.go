@@ -0,0 +1,39 @@
+package bitbucket
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+)
+
+// replacePullRequestEvents replaces the stored timeline of a pull request, as Bitbucket Cloud activity has no IDs.
+func replacePullRequestEvents(ctx context.Context, db *sqlx.DB, pr *pullRequest, events []*pullRequestEvent) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pull_request_events WHERE host = $1 AND pr_id = $2`,
+		pr.Host, pr.PrID,
+	); err != nil {
+		return errors.Wrap(err, "failed to delete pull request events")
+	}
+	if len(events) > 0 {
+		if _, err := tx.NamedExecContext(ctx, `
+			INSERT INTO pull_request_events (host, provider, event_id, pr_id, repo, event, actor, created_at, commit_id, data)
+			VALUES (:host, :provider, :event_id, :pr_id, :repo, :event, :actor, :created_at, :commit_id, :data)
+			`, events,
+		); err != nil {
+			return errors.Wrap(err, "failed to insert pull request events")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit pull request events")
+	}
+	metrics.AddRowsUpserted("pull_request_events", len(events))
+	return nil
+}
@@ -0,0 +1,203 @@
+package bitbucket
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const serverPageLimit = 100
+
+// serverReviewActions maps the review actions of Bitbucket Server to the GitHub review state they're stored as.
+var serverReviewActions = map[string]string{
+	"APPROVED":   reviewStateApproved,
+	"UNAPPROVED": reviewStateDismissed,
+	"REVIEWED":   reviewStateChangesRequested, // "Needs work"
+}
+
+type serverPage[T any] struct {
+	Values        []*T `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+type serverUser struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type serverPullRequest struct {
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	State       string  `json:"state"`
+	Draft       bool    `json:"draft"`
+	Author      struct {
+		User serverUser `json:"user"`
+	} `json:"author"`
+	CreatedDate int64 `json:"createdDate"`
+	UpdatedDate int64 `json:"updatedDate"`
+	FromRef     struct {
+		DisplayID    string `json:"displayId"`
+		LatestCommit string `json:"latestCommit"`
+	} `json:"fromRef"`
+}
+
+type serverComment struct {
+	ID          int64      `json:"id"`
+	Text        string     `json:"text"`
+	Author      serverUser `json:"author"`
+	CreatedDate int64      `json:"createdDate"`
+	UpdatedDate int64      `json:"updatedDate"`
+}
+
+type serverActivity struct {
+	ID          int64          `json:"id"`
+	Action      string         `json:"action"`
+	User        serverUser     `json:"user"`
+	CreatedDate int64          `json:"createdDate"`
+	Comment     *serverComment `json:"comment"`
+	Commit      *struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+type serverCommit struct {
+	ID              string `json:"id"`
+	AuthorTimestamp int64  `json:"authorTimestamp"`
+}
+
+type serverBuildStatus struct {
+	State     string `json:"state"`
+	DateAdded int64  `json:"dateAdded"`
+}
+
+// serverAPI is the Bitbucket Server (Data Center) API, where repositories are identified as PROJECT/repo_slug.
+type serverAPI struct {
+	client  *Client
+	baseURL *url.URL
+}
+
+func (a *serverAPI) repoURL(repo string, elem ...string) *url.URL {
+	project, slug, _ := strings.Cut(repo, "/")
+	return a.baseURL.JoinPath(append([]string{"rest/api/1.0/projects", project, "repos", slug}, elem...)...)
+}
+
+// listPullRequests lists pull requests with order NEWEST, which orders them by their last update.
+func (a *serverAPI) listPullRequests(ctx context.Context, repo string, cursor string) ([]*pullRequest, string, error) {
+	u := a.repoURL(repo, "pull-requests")
+	u.RawQuery = url.Values{
+		"state": {"ALL"},
+		"order": {"NEWEST"},
+		"limit": {strconv.Itoa(serverPageLimit)},
+		"start": {lo.Ternary(cursor == "", "0", cursor)},
+	}.Encode()
+
+	var page serverPage[serverPullRequest]
+	if err := a.client.get(ctx, u, &page); err != nil {
+		return nil, "", errors.Wrap(err, "failed to list pull requests")
+	}
+	prs := lo.Map(page.Values, func(p *serverPullRequest, _ int) *pullRequest {
+		pr := newPullRequest(a.client.host, repo, p.ID)
+		pr.Username = p.Author.User.Name
+		pr.Title = p.Title
+		pr.Body = p.Description
+		pr.State = stateOf(p.State)
+		pr.Draft = p.Draft
+		pr.CreatedAt = time.UnixMilli(p.CreatedDate).UTC()
+		pr.UpdatedAt = time.UnixMilli(p.UpdatedDate).UTC()
+		pr.HeadSHA = p.FromRef.LatestCommit
+		pr.HeadRef = p.FromRef.DisplayID
+		pr.Data = p
+		return pr
+	})
+	if page.IsLastPage {
+		return prs, "", nil
+	}
+	return prs, strconv.Itoa(page.NextPageStart), nil
+}
+
+func (a *serverAPI) getTimeline(ctx context.Context, pr *pullRequest) (*timeline, error) {
+	activities, err := serverListAll[serverActivity](ctx, a.client, a.repoURL(pr.Repo, "pull-requests", strconv.Itoa(pr.Number), "activities"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list activities")
+	}
+	t := &timeline{reviews: []*pullRequestReview{}, comments: []*pullRequestComment{}, events: []*pullRequestEvent{}}
+	for _, activity := range activities {
+		a.addActivity(pr, t, activity)
+	}
+
+	commits, err := serverListAll[serverCommit](ctx, a.client, a.repoURL(pr.Repo, "pull-requests", strconv.Itoa(pr.Number), "commits"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list commits")
+	}
+	pr.FirstCommitAt = earliest(lo.Map(commits, func(c *serverCommit, _ int) time.Time {
+		return time.UnixMilli(c.AuthorTimestamp).UTC()
+	}))
+
+	if pr.HeadSHA != "" {
+		statuses, err := serverListAll[serverBuildStatus](ctx, a.client, a.baseURL.JoinPath("rest/build-status/1.0/commits", pr.HeadSHA))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list build statuses")
+		}
+		setPullRequestCI(pr, lo.Map(statuses, func(s *serverBuildStatus, _ int) *buildStatus {
+			// Build statuses are replaced as builds progress, so the time they were added is also when they were last updated
+			dateAdded := time.UnixMilli(s.DateAdded).UTC()
+			return &buildStatus{state: s.State, createdAt: dateAdded, updatedAt: dateAdded}
+		}))
+	}
+	return t, nil
+}
+
+func (a *serverAPI) addActivity(pr *pullRequest, t *timeline, activity *serverActivity) {
+	createdAt := time.UnixMilli(activity.CreatedDate).UTC()
+	event := newPullRequestEvent(pr, strings.ToLower(activity.Action), activity.User.Name, createdAt, activity)
+	event.EventID = &activity.ID
+	t.events = append(t.events, event)
+
+	if state, ok := serverReviewActions[activity.Action]; ok {
+		t.reviews = append(t.reviews, newPullRequestReview(pr, activity.ID, activity.User.Name, state, createdAt, activity))
+	}
+	if activity.Action == "MERGED" {
+		pr.MergedAt = &createdAt
+		if activity.Commit != nil {
+			pr.MergeCommitSHA = &activity.Commit.ID
+		}
+	}
+	if activity.Action == "COMMENTED" && activity.Comment != nil {
+		c := activity.Comment
+		t.comments = append(t.comments, &pullRequestComment{
+			Host:      pr.Host,
+			Provider:  provider,
+			CommentID: c.ID,
+			PrID:      pr.PrID,
+			Repo:      pr.Repo,
+			Username:  c.Author.Name,
+			Body:      c.Text,
+			CreatedAt: time.UnixMilli(c.CreatedDate).UTC(),
+			UpdatedAt: time.UnixMilli(c.UpdatedDate).UTC(),
+			Data:      c,
+		})
+	}
+}
+
+func serverListAll[T any](ctx context.Context, client *Client, u *url.URL) ([]*T, error) {
+	entities := []*T{}
+	for start := 0; ; {
+		u.RawQuery = url.Values{"start": {strconv.Itoa(start)}, "limit": {strconv.Itoa(serverPageLimit)}}.Encode()
+		var page serverPage[T]
+		if err := client.get(ctx, u, &page); err != nil {
+			return nil, err
+		}
+		entities = append(entities, page.Values...)
+		if page.IsLastPage || len(page.Values) == 0 {
+			return entities, nil
+		}
+		start = page.NextPageStart
+	}
+}
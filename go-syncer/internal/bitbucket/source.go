@@ -0,0 +1,37 @@
+package bitbucket
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+)
+
+const sourceName = "bitbucket"
+
+// Source syncs the pull requests of the configured Bitbucket repositories.
+type Source struct {
+	config *config.BitbucketSource
+	client *Client
+}
+
+func NewSource(source *config.BitbucketSource) (*Source, error) {
+	client, err := NewClient(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{config: source, client: client}, nil
+}
+
+func (s *Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Schedule() string {
+	return s.config.Schedule
+}
+
+func (s *Source) Sync(ctx context.Context, db *sqlx.DB) error {
+	return Sync(ctx, db, s.config, s.client)
+}
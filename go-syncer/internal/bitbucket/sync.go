@@ -0,0 +1,152 @@
+package bitbucket
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/locks"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+const (
+	syncBackTime      = 6 * 30 * 24 * time.Hour
+	prSyncConcurrency = 3
+)
+
+// Sync syncs the pull requests of the configured repositories into the pull request tables.
+// A repository that fails to sync doesn't stop the others, the returned error lists the failures.
+func Sync(ctx context.Context, db *sqlx.DB, source *config.BitbucketSource, client *Client) error {
+	log := logging.MustFromContext(ctx).WithValues("source", "bitbucket", "host", client.host)
+	ctx = logging.NewContext(ctx, log)
+	log.Info("Syncing repositories")
+
+	failures := []string{}
+	for _, repo := range source.Repositories {
+		repoLog := log.WithValues("repo", repo)
+		lock, locked, err := locks.TryLock(ctx, db, locks.RepoKey(client.host, repo))
+		if err != nil {
+			repoLog.Error(err, "Failed to lock repository")
+			failures = append(failures, errors.Wrapf(err, "failed to lock repository %s", repo).Error())
+			continue
+		}
+		if !locked {
+			repoLog.Info("Repository is being synced by another replica, skipping")
+			continue
+		}
+		repoLog.Info("Syncing repository")
+		repoCtx, repoRun := syncrun.StartRepo(logging.NewContext(ctx, repoLog), client.host, repo)
+		if err := syncRepoPullRequests(repoCtx, db, client, repo); err != nil {
+			repoLog.Error(err, "Failed to sync pull requests")
+			repoRun.Fail(errors.Wrap(err, "failed to sync pull requests"))
+			failures = append(failures, errors.Wrapf(err, "failed to sync pull requests of %s", repo).Error())
+		} else {
+			repoLog.Info("Synced repository")
+		}
+		repoRun.Finish(repoCtx)
+		lock.Release(repoCtx)
+	}
+
+	log.Info("Synced repositories", "failures", len(failures))
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// syncRepoPullRequests syncs pull requests from the most recently updated one until it reaches the last sync time.
+// Neither API filters pull requests by update time, so the watermark only moves once the whole range was synced.
+func syncRepoPullRequests(ctx context.Context, db *sqlx.DB, client *Client, repo string) error {
+	log := logging.MustFromContext(ctx)
+
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, client.host, repo, pg.SyncEntityPullRequests)
+	if err != nil {
+		return err
+	}
+	syncFrom := time.Now().UTC().Add(-syncBackTime)
+	if lastSynced != nil {
+		log.Info("Last synced time found, syncing from latest to last sync time", "last_synced", lastSynced)
+		syncFrom = *lastSynced
+	}
+
+	var latestUpdatedAt *time.Time
+	for cursor := ""; ; {
+		prs, next, err := client.api.listPullRequests(ctx, repo, cursor)
+		if err != nil {
+			return err
+		}
+		prsToSync := []*pullRequest{}
+		for _, pr := range prs {
+			if !pr.UpdatedAt.After(syncFrom) {
+				break
+			}
+			prsToSync = append(prsToSync, pr)
+		}
+		if len(prsToSync) > 0 {
+			if latestUpdatedAt == nil {
+				latestUpdatedAt = &prsToSync[0].UpdatedAt
+			}
+			if err := syncPullRequestsChunk(ctx, db, client, prsToSync); err != nil {
+				return errors.Wrap(err, "failed to sync pull requests chunk")
+			}
+			log.Info("Synced pull requests", "prs", len(prsToSync), "last_pr_updated_at", prsToSync[len(prsToSync)-1].UpdatedAt)
+		}
+		if len(prsToSync) < len(prs) || next == "" { // If we filtered, it means we reached the last synced time
+			break
+		}
+		cursor = next
+	}
+
+	if latestUpdatedAt != nil {
+		log.Info("Updating last synced time", "last_synced", latestUpdatedAt)
+		if err := pg.UpdateLastSyncAt(ctx, db, client.host, repo, pg.SyncEntityPullRequests, *latestUpdatedAt); err != nil {
+			return errors.Wrap(err, "failed to update last synced time")
+		}
+	}
+	return nil
+}
+
+func syncPullRequestsChunk(ctx context.Context, db *sqlx.DB, client *Client, prs []*pullRequest) error {
+	timelines := make([]*timeline, len(prs))
+	sem := make(chan struct{}, prSyncConcurrency)
+	eg := errgroup.Group{}
+	for i, pr := range prs {
+		i, pr := i, pr
+		sem <- struct{}{}
+		eg.Go(func() error {
+			defer func() { <-sem }()
+			t, err := client.api.getTimeline(ctx, pr)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get timeline of pull request %d", pr.Number)
+			}
+			timelines[i] = t
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := pg.UpsertPullRequests(ctx, db, prs); err != nil {
+		return err
+	}
+	for i, pr := range prs {
+		if err := pg.UpsertPullRequestReviews(ctx, db, timelines[i].reviews); err != nil {
+			return err
+		}
+		if err := pg.UpsertPullRequestComments(ctx, db, timelines[i].comments); err != nil {
+			return err
+		}
+		if err := replacePullRequestEvents(ctx, db, pr, timelines[i].events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,12 +1,18 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/pkg/errors"
 )
 
+const DefaultGitHubSourceName = "github"
+
 type GitHubRepository string
 
 func (r GitHubRepository) Valid() bool {
@@ -21,10 +27,196 @@ func (r GitHubRepository) Name() string {
 	return strings.Split(string(r), "/")[1]
 }
 
+type GitHubSyncBackend string
+
+const (
+	GitHubSyncBackendREST    GitHubSyncBackend = "rest"
+	GitHubSyncBackendGraphQL GitHubSyncBackend = "graphql"
+)
+
+func (b GitHubSyncBackend) Valid() bool {
+	return b == GitHubSyncBackendREST || b == GitHubSyncBackendGraphQL
+}
+
+// GitHubSource is a GitHub instance to sync from, either github.com or a GitHub Enterprise Server.
+type GitHubSource struct {
+	Name                string
+	BaseURL             string             `env:"BASE_URL"`
+	UploadURL           string             `env:"UPLOAD_URL"`
+	CABundlePath        string             `env:"CA_BUNDLE_PATH"`
+	Tokens              []string           `env:"TOKENS"`
+	AppID               int64              `env:"APP_ID"`
+	AppPrivateKey       string             `env:"APP_PRIVATE_KEY"`
+	AppPrivateKeyFile   string             `env:"APP_PRIVATE_KEY_PATH,file"`
+	Repositories        []GitHubRepository `env:"REPOSITORIES"`
+	Organizations       []string           `env:"ORGANIZATIONS"`
+	RepositoriesInclude []string           `env:"REPOSITORIES_INCLUDE"`
+	RepositoriesExclude []string           `env:"REPOSITORIES_EXCLUDE"`
+	SkipArchived        bool               `env:"SKIP_ARCHIVED"`
+	SkipForks           bool               `env:"SKIP_FORKS"`
+	WebhookSecret       string             `env:"WEBHOOK_SECRET"`
+	SyncBackend         GitHubSyncBackend  `env:"SYNC_BACKEND"         envDefault:"rest"`
+	SyncWorkflowRuns    bool               `env:"SYNC_WORKFLOW_RUNS"`
+	SyncDeployments     bool               `env:"SYNC_DEPLOYMENTS"`
+	SyncReleases        bool               `env:"SYNC_RELEASES"`
+	SyncIssues          bool               `env:"SYNC_ISSUES"`
+	Schedule            string             `env:"SCHEDULE"`
+}
+
+func (s *GitHubSource) AppEnabled() bool {
+	return s.AppID != 0
+}
+
+func (s *GitHubSource) Enabled() bool {
+	return len(s.Repositories) > 0 || len(s.Organizations) > 0
+}
+
+func (s *GitHubSource) validate() error {
+	for _, repo := range s.Repositories {
+		if !repo.Valid() {
+			return errors.Errorf("invalid GitHub repository: %s", repo)
+		}
+	}
+
+	for _, patterns := range [][]string{s.RepositoriesInclude, s.RepositoriesExclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.Errorf("invalid GitHub repository pattern: %s", pattern)
+			}
+		}
+	}
+
+	if s.AppPrivateKey == "" {
+		s.AppPrivateKey = s.AppPrivateKeyFile
+	}
+	if s.AppEnabled() && s.AppPrivateKey == "" {
+		return errors.New("GitHub App private key is required when GitHub App ID is set")
+	}
+
+	if !s.SyncBackend.Valid() {
+		return errors.Errorf("invalid GitHub sync backend: %s", s.SyncBackend)
+	}
+
+	return nil
+}
+
+// GitLabSource is a GitLab instance to sync merge requests from, either gitlab.com or a self-managed instance.
+type GitLabSource struct {
+	BaseURL      string   `env:"BASE_URL"       envDefault:"https://gitlab.com"`
+	CABundlePath string   `env:"CA_BUNDLE_PATH"`
+	Token        string   `env:"TOKEN"`
+	Projects     []string `env:"PROJECTS"`
+	Groups       []string `env:"GROUPS"`
+	Schedule     string   `env:"SCHEDULE"`
+}
+
+func (s *GitLabSource) Enabled() bool {
+	return s.Token != "" && (len(s.Projects) > 0 || len(s.Groups) > 0)
+}
+
+func (s *GitLabSource) validate() error {
+	if _, err := url.Parse(s.BaseURL); err != nil {
+		return errors.Wrap(err, "invalid GitLab base URL")
+	}
+	for _, project := range s.Projects {
+		if !strings.Contains(project, "/") {
+			return errors.Errorf("invalid GitLab project: %s", project)
+		}
+	}
+	return nil
+}
+
+const BitbucketCloudBaseURL = "https://api.bitbucket.org/2.0"
+
+// BitbucketSource is a Bitbucket Cloud workspace or a Bitbucket Server (Data Center) instance to sync pull requests from.
+type BitbucketSource struct {
+	BaseURL      string   `env:"BASE_URL"       envDefault:"https://api.bitbucket.org/2.0"`
+	CABundlePath string   `env:"CA_BUNDLE_PATH"`
+	Username     string   `env:"USERNAME"`
+	Token        string   `env:"TOKEN"`
+	Repositories []string `env:"REPOSITORIES"`
+	Schedule     string   `env:"SCHEDULE"`
+}
+
+func (s *BitbucketSource) Enabled() bool {
+	return s.Token != "" && len(s.Repositories) > 0
+}
+
+// Server is whether the source is a Bitbucket Server instance rather than Bitbucket Cloud.
+func (s *BitbucketSource) Server() bool {
+	return strings.TrimSuffix(s.BaseURL, "/") != BitbucketCloudBaseURL
+}
+
+func (s *BitbucketSource) validate() error {
+	if _, err := url.Parse(s.BaseURL); err != nil {
+		return errors.Wrap(err, "invalid Bitbucket base URL")
+	}
+	for _, repo := range s.Repositories {
+		if !strings.Contains(repo, "/") {
+			return errors.Errorf("invalid Bitbucket repository: %s", repo)
+		}
+	}
+	return nil
+}
+
+// Date is a day in YYYY-MM-DD format.
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	t, err := time.Parse(time.DateOnly, string(text))
+	if err != nil {
+		return errors.Wrapf(err, "invalid date: %s", text)
+	}
+	d.Time = t
+	return nil
+}
+
+// Jira is the Jira Cloud site to sync issues from.
+type Jira struct {
+	SiteURL          string   `env:"SITE_URL"`
+	Username         string   `env:"USERNAME"`
+	APIToken         string   `env:"API_TOKEN"`
+	Projects         []string `env:"PROJECTS"`
+	SyncFrom         Date     `env:"SYNC_FROM"`
+	ForceResyncFrom  Date     `env:"FORCE_RESYNC_FROM"`
+	IssueKeyPatterns []string `env:"ISSUE_KEY_PATTERNS" envSeparator:";"`
+	Schedule         string   `env:"SCHEDULE"`
+	LinkSchedule     string   `env:"LINK_SCHEDULE"`
+}
+
+func (j *Jira) Enabled() bool {
+	return j.APIToken != "" && len(j.Projects) > 0
+}
+
+// LinkingEnabled is whether pull requests are linked to Jira issues, which only requires issue key patterns or
+// projects to derive them from.
+func (j *Jira) LinkingEnabled() bool {
+	return len(j.IssueKeyPatterns) > 0 || len(j.Projects) > 0
+}
+
+func (j *Jira) validate() error {
+	if j.Enabled() && j.SiteURL == "" {
+		return errors.New("Jira site URL is required when Jira projects are set")
+	}
+	return nil
+}
+
 type Config struct {
-	PgURL              string             `env:"PG_URL"`
-	GitHubTokens       []string           `env:"GITHUB_TOKENS"`
-	GitHubRepositories []GitHubRepository `env:"GITHUB_REPOSITORIES"`
+	PgURL                   string        `env:"PG_URL"`
+	HTTPAddr                string        `env:"HTTP_ADDR"                  envDefault:":8080"`
+	SyncSchedule            string        `env:"SYNC_SCHEDULE"              envDefault:"@every 10m"`
+	DORASchedule            string        `env:"DORA_SCHEDULE"`
+	ReadyMaxSyncAge         time.Duration `env:"READY_MAX_SYNC_AGE"         envDefault:"1h"`
+	LiveMaxSyncDuration     time.Duration `env:"LIVE_MAX_SYNC_DURATION"     envDefault:"12h"`
+	JobWorkers              int           `env:"JOB_WORKERS"                envDefault:"2"`
+	GitHub                  GitHubSource  `envPrefix:"GITHUB_"`
+	GitHubEnterpriseSources []string      `env:"GITHUB_ENTERPRISE_SOURCES"`
+	GitHubEnterprise        []*GitHubSource
+	GitLab                  GitLabSource    `envPrefix:"GITLAB_"`
+	Bitbucket               BitbucketSource `envPrefix:"BITBUCKET_"`
+	Jira                    Jira            `envPrefix:"JIRA_"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -34,11 +226,55 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.Wrap(err, "failed to parse environment variables")
 	}
 
-	for _, repo := range cfg.GitHubRepositories {
-		if !repo.Valid() {
-			return nil, errors.Errorf("invalid GitHub repository: %s", repo)
+	cfg.GitHub.Name = DefaultGitHubSourceName
+	if err := cfg.GitHub.validate(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range cfg.GitHubEnterpriseSources {
+		source := &GitHubSource{Name: strings.ToLower(name)}
+		prefix := fmt.Sprintf("GITHUB_ENTERPRISE_%s_", strings.ToUpper(name))
+		if err := env.ParseWithOptions(source, env.Options{Prefix: prefix}); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse GitHub Enterprise source %s", name)
 		}
+		if source.BaseURL == "" {
+			return nil, errors.Errorf("%sBASE_URL is required", prefix)
+		}
+		if err := source.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid GitHub Enterprise source %s", name)
+		}
+		cfg.GitHubEnterprise = append(cfg.GitHubEnterprise, source)
 	}
 
+	if err := cfg.GitLab.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Bitbucket.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Jira.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg.setDefaultSchedules()
+
 	return &cfg, nil
 }
+
+// setDefaultSchedules schedules the sources without a schedule of their own on SYNC_SCHEDULE.
+func (c *Config) setDefaultSchedules() {
+	schedules := []*string{&c.DORASchedule, &c.GitLab.Schedule, &c.Bitbucket.Schedule, &c.Jira.Schedule, &c.Jira.LinkSchedule}
+	for _, source := range c.GitHubSources() {
+		schedules = append(schedules, &source.Schedule)
+	}
+	for _, schedule := range schedules {
+		if *schedule == "" {
+			*schedule = c.SyncSchedule
+		}
+	}
+}
+
+// GitHubSources returns github.com followed by the GitHub Enterprise Server sources.
+func (c *Config) GitHubSources() []*GitHubSource {
+	return append([]*GitHubSource{&c.GitHub}, c.GitHubEnterprise...)
+}
@@ -0,0 +1,120 @@
+package dora
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+// Materialize links deployed commits to the pull requests that merged them, then recomputes the daily DORA metrics
+// of every repository and environment:
+//   - deployment frequency: successful deployments per day
+//   - lead time for changes: first commit of a pull request to its first successful deployment
+//   - change failure rate: failed deployments out of all finished deployments
+//   - time to restore: first failed deployment to the next successful deployment to the same environment
+func Materialize(ctx context.Context, db *sqlx.DB) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Materializing DORA metrics")
+
+	if err := linkDeploymentPullRequests(ctx, db); err != nil {
+		return err
+	}
+	if err := materializeDailyMetrics(ctx, db); err != nil {
+		return err
+	}
+
+	log.Info("Materialized DORA metrics")
+	return nil
+}
+
+// linkDeploymentPullRequests runs on every sync, as pull requests may be synced after the deployment that shipped them.
+func linkDeploymentPullRequests(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO deployment_pull_requests (host, deployment_id, pr_id, repo, environment, deployed_at)
+		SELECT d.host, d.deployment_id, pr.pr_id, d.repo, d.environment, d.succeeded_at
+		FROM deployment_commits dc
+		JOIN deployments d ON d.host = dc.host AND d.deployment_id = dc.deployment_id
+		JOIN pull_requests pr ON pr.host = dc.host AND pr.repo = dc.repo AND pr.merge_commit_sha = dc.sha
+		ON CONFLICT (host, deployment_id, pr_id) DO NOTHING
+	`); err != nil {
+		return errors.Wrap(err, "failed to link deployments to pull requests")
+	}
+	return nil
+}
+
+func materializeDailyMetrics(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, `
+		WITH finished AS (
+			SELECT host, repo, environment, COALESCE(succeeded_at, failed_at) AS finished_at, succeeded_at IS NOT NULL AS succeeded
+			FROM deployments
+			WHERE succeeded_at IS NOT NULL OR failed_at IS NOT NULL
+		),
+		-- Each failure belongs to the streak ended by the next success
+		streaks AS (
+			SELECT *, COALESCE(SUM(CASE WHEN succeeded THEN 1 ELSE 0 END) OVER (
+				PARTITION BY host, repo, environment ORDER BY finished_at ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+			), 0) AS streak
+			FROM finished
+		),
+		failure_streaks AS (
+			SELECT host, repo, environment, streak, MIN(finished_at) AS failed_at
+			FROM streaks
+			WHERE NOT succeeded
+			GROUP BY host, repo, environment, streak
+		),
+		restores AS (
+			SELECT s.host, s.repo, s.environment, s.finished_at::DATE AS day,
+				COUNT(*) AS restores,
+				AVG(EXTRACT(EPOCH FROM s.finished_at - fs.failed_at))::INT8 AS time_to_restore_seconds_avg
+			FROM streaks s
+			JOIN failure_streaks fs USING (host, repo, environment, streak)
+			WHERE s.succeeded
+			GROUP BY s.host, s.repo, s.environment, s.finished_at::DATE
+		),
+		lead_times AS (
+			SELECT dp.host, dp.repo, dp.environment, dp.deployed_at::DATE AS day,
+				PERCENTILE_CONT(0.5) WITHIN GROUP (
+					ORDER BY EXTRACT(EPOCH FROM dp.deployed_at - COALESCE(pr.first_commit_at, pr.created_at))
+				)::INT8 AS lead_time_seconds_median
+			FROM (
+				-- A pull request's changes are delivered by its first deployment to each environment
+				SELECT DISTINCT ON (host, pr_id, environment) *
+				FROM deployment_pull_requests
+				ORDER BY host, pr_id, environment, deployed_at
+			) dp
+			JOIN pull_requests pr ON pr.host = dp.host AND pr.pr_id = dp.pr_id
+			GROUP BY dp.host, dp.repo, dp.environment, dp.deployed_at::DATE
+		),
+		days AS (
+			SELECT host, repo, environment, finished_at::DATE AS day,
+				COUNT(*) FILTER (WHERE succeeded) AS deployments,
+				COUNT(*) FILTER (WHERE NOT succeeded) AS failed_deployments,
+				COUNT(*) FILTER (WHERE NOT succeeded)::DOUBLE PRECISION / COUNT(*) AS change_failure_rate
+			FROM finished
+			GROUP BY host, repo, environment, finished_at::DATE
+		)
+		INSERT INTO dora_daily_metrics (
+			host, repo, environment, day, deployments, failed_deployments, change_failure_rate, lead_time_seconds_median,
+			restores, time_to_restore_seconds_avg, computed_at
+		)
+		SELECT d.host, d.repo, d.environment, d.day, d.deployments, d.failed_deployments, d.change_failure_rate,
+			lt.lead_time_seconds_median, COALESCE(r.restores, 0), r.time_to_restore_seconds_avg, NOW()
+		FROM days d
+		LEFT JOIN lead_times lt USING (host, repo, environment, day)
+		LEFT JOIN restores r USING (host, repo, environment, day)
+		ON CONFLICT (host, repo, environment, day) DO UPDATE
+		SET deployments = EXCLUDED.deployments,
+			failed_deployments = EXCLUDED.failed_deployments,
+			change_failure_rate = EXCLUDED.change_failure_rate,
+			lead_time_seconds_median = EXCLUDED.lead_time_seconds_median,
+			restores = EXCLUDED.restores,
+			time_to_restore_seconds_avg = EXCLUDED.time_to_restore_seconds_avg,
+			computed_at = EXCLUDED.computed_at
+	`); err != nil {
+		return errors.Wrap(err, "failed to materialize daily DORA metrics")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package dora
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/locks"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+const sourceName = "dora"
+
+// Source materializes the DORA metrics from the deployments synced by the GitHub sources.
+type Source struct {
+	schedule string
+}
+
+func NewSource(schedule string) *Source {
+	return &Source{schedule: schedule}
+}
+
+func (s *Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Schedule() string {
+	return s.schedule
+}
+
+// Sync materializes the metrics on one replica at a time, as every replica would recompute the same metrics.
+func (s *Source) Sync(ctx context.Context, db *sqlx.DB) error {
+	lock, locked, err := locks.TryLock(ctx, db, sourceName)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		logging.MustFromContext(ctx).Info("DORA metrics are being materialized by another replica, skipping")
+		return nil
+	}
+	defer lock.Release(ctx)
+	return Materialize(ctx, db)
+}
@@ -0,0 +1,149 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const (
+	checkRunsPerPage      = 100
+	commitStatusesPerPage = 100
+
+	ciStateSuccess = "success"
+	ciStateFailure = "failure"
+	ciStatePending = "pending"
+)
+
+// greenCheckRunConclusions are the conclusions that don't block a pull request.
+var greenCheckRunConclusions = []string{"success", "neutral", "skipped"}
+
+// syncPullRequestChecks syncs the check runs and commit statuses of the pull request's head commit,
+// and rolls them up into its CI state.
+func syncPullRequestChecks(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, pr *pullRequest,
+) error {
+	if pr.HeadSHA == "" {
+		return nil
+	}
+
+	checkRuns := []*pullRequestCheckRun{}
+	checkRunsOpt := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: checkRunsPerPage}}
+	for {
+		page, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.CheckRun, *github.Response, error) {
+				results, resp, err := client.Checks.ListCheckRunsForRef(ctx, *repo.Owner.Login, *repo.Name, pr.HeadSHA, checkRunsOpt)
+				if err != nil {
+					return nil, resp, err
+				}
+				return results.CheckRuns, resp, nil
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list check runs")
+		}
+		for _, checkRun := range page {
+			checkRuns = append(checkRuns, newPullRequestCheckRun(repo, pr.PrID, checkRun))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		checkRunsOpt.Page = resp.NextPage
+	}
+	if err := upsertPullRequestCheckRuns(ctx, db, checkRuns); err != nil {
+		return errors.Wrap(err, "failed to insert pull request check runs")
+	}
+
+	statuses := []*pullRequestCommitStatus{}
+	statusesOpt := &github.ListOptions{PerPage: commitStatusesPerPage}
+	for {
+		page, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.RepoStatus, *github.Response, error) {
+				return client.Repositories.ListStatuses(ctx, *repo.Owner.Login, *repo.Name, pr.HeadSHA, statusesOpt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list commit statuses")
+		}
+		for _, status := range page {
+			statuses = append(statuses, newPullRequestCommitStatus(repo, pr.PrID, pr.HeadSHA, status))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		statusesOpt.Page = resp.NextPage
+	}
+	if err := upsertPullRequestCommitStatuses(ctx, db, statuses); err != nil {
+		return errors.Wrap(err, "failed to insert pull request commit statuses")
+	}
+
+	setPullRequestCI(pr, checkRuns, statuses)
+	return nil
+}
+
+// setPullRequestCI rolls the checks of the head commit up into a single state. CI starts with the first check run or
+// status, which approximates the push, and completes with the last one.
+func setPullRequestCI(pr *pullRequest, checkRuns []*pullRequestCheckRun, statuses []*pullRequestCommitStatus) {
+	pr.CIState, pr.CIStartedAt, pr.CICompletedAt = nil, nil, nil
+	if len(checkRuns) == 0 && len(statuses) == 0 {
+		return
+	}
+
+	var startedAt, completedAt *time.Time
+	observe := func(start, end *time.Time) {
+		if start != nil && (startedAt == nil || start.Before(*startedAt)) {
+			startedAt = start
+		}
+		if end != nil && (completedAt == nil || end.After(*completedAt)) {
+			completedAt = end
+		}
+	}
+
+	pending, failed := false, false
+	for _, checkRun := range checkRuns {
+		observe(checkRun.StartedAt, checkRun.CompletedAt)
+		switch {
+		case checkRun.CompletedAt == nil:
+			pending = true
+		case !lo.Contains(greenCheckRunConclusions, lo.FromPtr(checkRun.Conclusion)):
+			failed = true
+		}
+	}
+
+	// Statuses are listed newest first, each context reports a pending status and then its result
+	latestByContext := map[string]*pullRequestCommitStatus{}
+	for _, status := range statuses {
+		createdAt := status.CreatedAt
+		observe(&createdAt, nil)
+		if latest, ok := latestByContext[status.Context]; !ok || status.CreatedAt.After(latest.CreatedAt) {
+			latestByContext[status.Context] = status
+		}
+	}
+	for _, status := range latestByContext {
+		switch status.State {
+		case ciStatePending:
+			pending = true
+		case ciStateSuccess:
+		default:
+			failed = true
+		}
+		if status.State != ciStatePending {
+			updatedAt := status.UpdatedAt
+			observe(nil, &updatedAt)
+		}
+	}
+
+	state := ciStateSuccess
+	switch {
+	case failed:
+		state = ciStateFailure
+	case pending:
+		state = ciStatePending
+		completedAt = nil
+	}
+	pr.CIState, pr.CIStartedAt, pr.CICompletedAt = &state, startedAt, completedAt
+}
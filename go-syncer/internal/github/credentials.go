@@ -0,0 +1,136 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/google/go-github/v62/github"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+const appInstallationsPerPage = 100
+
+// Credentials resolves the tokens to use for a repository owner.
+// With personal access tokens, all owners share the same tokens. When authenticating as a GitHub App,
+// each owner gets the auto-refreshing installation token of the app installation on it.
+type Credentials struct {
+	endpoint      *endpoint
+	tokenManager  *TokenManager
+	appsTransport *ghinstallation.AppsTransport
+	installations map[string]*TokenManager
+	mu            sync.Mutex
+}
+
+func NewCredentials(source *config.GitHubSource) (*Credentials, error) {
+	endpoint, err := newEndpoint(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GitHub endpoint")
+	}
+	if !source.AppEnabled() {
+		return &Credentials{
+			endpoint:     endpoint,
+			tokenManager: newStaticTokenManager(endpoint, source.Tokens),
+		}, nil
+	}
+
+	appsTransport, err := ghinstallation.NewAppsTransport(endpoint.transport, source.AppID, []byte(source.AppPrivateKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GitHub App transport")
+	}
+	appsTransport.BaseURL = endpoint.appsBaseURL()
+	return &Credentials{
+		endpoint:      endpoint,
+		appsTransport: appsTransport,
+		installations: map[string]*TokenManager{},
+	}, nil
+}
+
+func (c *Credentials) ForOwner(ctx context.Context, owner string) (*TokenManager, error) {
+	if c.appsTransport == nil {
+		return c.tokenManager, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := strings.ToLower(owner)
+	if tokenManager, ok := c.installations[key]; ok {
+		if !installationGone(ctx, tokenManager) {
+			return tokenManager, nil
+		}
+		logging.MustFromContext(ctx).Info("GitHub App installation is gone, looking it up again", "owner", owner)
+		delete(c.installations, key)
+	}
+	if err := c.refreshInstallations(ctx); err != nil {
+		return nil, err
+	}
+	tokenManager, ok := c.installations[key]
+	if !ok {
+		return nil, errors.Errorf("GitHub App is not installed on %s", owner)
+	}
+	return tokenManager, nil
+}
+
+// Exhausted is whether every token is rate limited, an app that wasn't used on any owner yet isn't exhausted.
+func (c *Credentials) Exhausted() bool {
+	if c.appsTransport == nil {
+		return c.tokenManager.IsExhausted()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tokenManager := range c.installations {
+		if !tokenManager.IsExhausted() {
+			return false
+		}
+	}
+	return len(c.installations) > 0
+}
+
+// installationGone is whether the token of an installation can't be refreshed because the app was uninstalled from the
+// owner, or reinstalled under another installation ID. The token is cached until it expires, so this is cheap.
+func installationGone(ctx context.Context, tokenManager *TokenManager) bool {
+	_, err := tokenManager.tokens[0].Token(ctx)
+	var httpErr *ghinstallation.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Response == nil {
+		return false
+	}
+	return httpErr.Response.StatusCode == http.StatusUnauthorized || httpErr.Response.StatusCode == http.StatusNotFound
+}
+
+func (c *Credentials) refreshInstallations(ctx context.Context) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Listing GitHub App installations")
+
+	client := c.endpoint.withURLs(github.NewClient(&http.Client{Transport: c.appsTransport}))
+	opt := &github.ListOptions{PerPage: appInstallationsPerPage}
+	for {
+		installations, resp, err := client.Apps.ListInstallations(ctx, opt)
+		if err != nil {
+			return errors.Wrap(err, "failed to list GitHub App installations")
+		}
+		for _, installation := range installations {
+			key := strings.ToLower(installation.GetAccount().GetLogin())
+			if _, ok := c.installations[key]; ok {
+				continue
+			}
+			log.Info("Found GitHub App installation", "account", installation.GetAccount().GetLogin(), "installation_id", installation.GetID())
+			transport := ghinstallation.NewFromAppsTransport(c.appsTransport, installation.GetID())
+			c.installations[key] = newTokenManager(c.endpoint, []tokenSource{
+				installationToken{Transport: transport, installationID: installation.GetID()},
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package github
+
+import (
+	"time"
+)
+
+const reviewStateApproved = "APPROVED"
+
+// pullRequestCycleTime breaks the lifetime of a pull request into phases:
+//   - coding: first commit to ready for review
+//   - pickup: ready for review to the first review
+//   - review: first review to the approval
+//   - merge: approval to merge
+//
+// CI time, from the first check on the head commit until all of them are green, overlaps the phases above.
+//
+// Durations are nil while a phase hasn't ended, or when its boundaries are out of order.
+type pullRequestCycleTime struct {
+	Host              string     `db:"host"`
+	PrID              int        `db:"pr_id"`
+	Repo              string     `db:"repo"`
+	Number            int        `db:"number"`
+	Username          string     `db:"username"`
+	FirstCommitAt     *time.Time `db:"first_commit_at"`
+	ReadyForReviewAt  time.Time  `db:"ready_for_review_at"`
+	FirstReviewAt     *time.Time `db:"first_review_at"`
+	ApprovedAt        *time.Time `db:"approved_at"`
+	MergedAt          *time.Time `db:"merged_at"`
+	CodingTimeSeconds *int64     `db:"coding_time_seconds"`
+	PickupTimeSeconds *int64     `db:"pickup_time_seconds"`
+	ReviewTimeSeconds *int64     `db:"review_time_seconds"`
+	MergeTimeSeconds  *int64     `db:"merge_time_seconds"`
+	CycleTimeSeconds  *int64     `db:"cycle_time_seconds"`
+	CITimeSeconds     *int64     `db:"ci_time_seconds"`
+}
+
+func newPullRequestCycleTime(pr *pullRequest, reviews []*pullRequestReview) *pullRequestCycleTime {
+	// Pull requests that were never drafts are ready for review when opened
+	readyForReviewAt := pr.CreatedAt
+	if pr.LastReadyForReviewAt != nil {
+		readyForReviewAt = *pr.LastReadyForReviewAt
+	}
+
+	var firstReviewAt, approvedAt *time.Time
+	for _, review := range reviews {
+		// Self reviews, pending reviews and reviews left while in draft don't pick up the pull request
+		if review.Username == pr.Username || review.SubmittedAt.IsZero() || review.SubmittedAt.Before(readyForReviewAt) {
+			continue
+		}
+		if pr.MergedAt != nil && review.SubmittedAt.After(*pr.MergedAt) {
+			continue
+		}
+		submittedAt := review.SubmittedAt
+		if firstReviewAt == nil || submittedAt.Before(*firstReviewAt) {
+			firstReviewAt = &submittedAt
+		}
+		// The last approval is the one that led to the merge
+		if review.State == reviewStateApproved && (approvedAt == nil || submittedAt.After(*approvedAt)) {
+			approvedAt = &submittedAt
+		}
+	}
+
+	startedAt := &pr.CreatedAt
+	if pr.FirstCommitAt != nil && pr.FirstCommitAt.Before(pr.CreatedAt) {
+		startedAt = pr.FirstCommitAt
+	}
+
+	var ciTimeSeconds *int64
+	if pr.CIState != nil && *pr.CIState == ciStateSuccess {
+		ciTimeSeconds = secondsBetween(pr.CIStartedAt, pr.CICompletedAt)
+	}
+
+	return &pullRequestCycleTime{
+		Host:              pr.Host,
+		PrID:              pr.PrID,
+		Repo:              pr.Repo,
+		Number:            pr.Number,
+		Username:          pr.Username,
+		FirstCommitAt:     pr.FirstCommitAt,
+		ReadyForReviewAt:  readyForReviewAt,
+		FirstReviewAt:     firstReviewAt,
+		ApprovedAt:        approvedAt,
+		MergedAt:          pr.MergedAt,
+		CodingTimeSeconds: secondsBetween(startedAt, &readyForReviewAt),
+		PickupTimeSeconds: secondsBetween(&readyForReviewAt, firstReviewAt),
+		ReviewTimeSeconds: secondsBetween(firstReviewAt, approvedAt),
+		MergeTimeSeconds:  secondsBetween(approvedAt, pr.MergedAt),
+		CycleTimeSeconds:  secondsBetween(startedAt, pr.MergedAt),
+		CITimeSeconds:     ciTimeSeconds,
+	}
+}
+
+func secondsBetween(from, to *time.Time) *int64 {
+	if from == nil || to == nil || to.Before(*from) {
+		return nil
+	}
+	seconds := int64(to.Sub(*from).Seconds())
+	return &seconds
+}
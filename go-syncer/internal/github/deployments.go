@@ -0,0 +1,181 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+)
+
+const (
+	deploymentsPerPage        = 100
+	deploymentStatusesPerPage = 100
+	compareCommitsPerPage     = 100
+
+	deploymentStateSuccess = "success"
+	deploymentStateFailure = "failure"
+	deploymentStateError   = "error"
+
+	// deploymentFinishTimeout is how long a deployment without a final status keeps being synced again.
+	// Some deployment tools never report a status.
+	deploymentFinishTimeout = 24 * time.Hour
+)
+
+// syncRepoDeployments syncs the deployments created since the last sync along with their statuses, and records the
+// commits each successful deployment shipped since the previous successful deployment to the same environment.
+func syncRepoDeployments(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing deployments")
+
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityDeployments)
+	if err != nil {
+		return err
+	}
+	syncFrom := time.Now().UTC().Add(-syncBackTime)
+	if lastSynced != nil {
+		log.Info("Last synced time found, syncing from latest to last sync time", "last_synced", lastSynced)
+		syncFrom = *lastSynced
+	}
+
+	deployments := []*github.Deployment{}
+	opt := &github.DeploymentsListOptions{ListOptions: github.ListOptions{PerPage: deploymentsPerPage}}
+	for {
+		page, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.Deployment, *github.Response, error) {
+				return client.Repositories.ListDeployments(ctx, *repo.Owner.Login, *repo.Name, opt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list deployments")
+		}
+		deploymentsToSync := lo.Filter(page, func(d *github.Deployment, _ int) bool {
+			return !d.GetCreatedAt().Before(syncFrom)
+		})
+		deployments = append(deployments, deploymentsToSync...)
+		if len(deploymentsToSync) < len(page) || resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	// Oldest first, so the previous deployment to an environment is stored before the next one is compared to it
+	deployments = lo.Reverse(deployments)
+
+	var latestCreatedAt, oldestUnfinishedCreatedAt *time.Time
+	for _, d := range deployments {
+		dep, err := syncDeployment(ctx, db, tokenManager, repo, d)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sync deployment %d", d.GetID())
+		}
+		createdAt := dep.CreatedAt
+		latestCreatedAt = &createdAt
+		unfinished := dep.SucceededAt == nil && dep.FailedAt == nil && time.Since(createdAt) < deploymentFinishTimeout
+		if unfinished && oldestUnfinishedCreatedAt == nil {
+			oldestUnfinishedCreatedAt = &createdAt
+		}
+	}
+	log.Info("Synced deployments", "deployments", len(deployments))
+
+	watermark := latestCreatedAt
+	if oldestUnfinishedCreatedAt != nil {
+		watermark = oldestUnfinishedCreatedAt
+	}
+	if watermark != nil {
+		log.Info("Updating last synced time", "last_synced", watermark)
+		if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityDeployments, *watermark); err != nil {
+			return errors.Wrap(err, "failed to update last synced time")
+		}
+	}
+
+	return nil
+}
+
+func syncDeployment(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, d *github.Deployment,
+) (*deployment, error) {
+	statuses := []*deploymentStatus{}
+	opt := &github.ListOptions{PerPage: deploymentStatusesPerPage}
+	for {
+		page, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.DeploymentStatus, *github.Response, error) {
+				return client.Repositories.ListDeploymentStatuses(ctx, *repo.Owner.Login, *repo.Name, d.GetID(), opt)
+			},
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list deployment statuses")
+		}
+		for _, status := range page {
+			statuses = append(statuses, newDeploymentStatus(repo, int(d.GetID()), status))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	dep := newDeployment(repo, d, statuses)
+	if err := upsertDeploymentStatuses(ctx, db, statuses); err != nil {
+		return nil, errors.Wrap(err, "failed to insert deployment statuses")
+	}
+	if err := upsertDeployments(ctx, db, []*deployment{dep}); err != nil {
+		return nil, errors.Wrap(err, "failed to insert deployment")
+	}
+	if dep.SucceededAt != nil {
+		if err := syncDeploymentCommits(ctx, db, tokenManager, repo, dep); err != nil {
+			return nil, errors.Wrap(err, "failed to sync deployment commits")
+		}
+	}
+	return dep, nil
+}
+
+func syncDeploymentCommits(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, dep *deployment,
+) error {
+	previousSHA, err := getPreviousDeploymentSHA(ctx, db, dep)
+	if err != nil {
+		return err
+	}
+	newDeploymentCommit := func(sha string) *deploymentCommit {
+		return &deploymentCommit{Host: dep.Host, DeploymentID: dep.DeploymentID, Repo: dep.Repo, SHA: sha}
+	}
+
+	// The first deployment to an environment has nothing to compare to
+	if previousSHA == nil {
+		return insertDeploymentCommits(ctx, db, []*deploymentCommit{newDeploymentCommit(dep.SHA)})
+	}
+	if *previousSHA == dep.SHA {
+		return nil
+	}
+
+	opt := &github.ListOptions{PerPage: compareCommitsPerPage}
+	for {
+		commits, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.RepositoryCommit, *github.Response, error) {
+				comparison, resp, err := client.Repositories.CompareCommits(ctx, *repo.Owner.Login, *repo.Name, *previousSHA, dep.SHA, opt)
+				if err != nil {
+					return nil, resp, err
+				}
+				return comparison.Commits, resp, nil
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to compare commits")
+		}
+		if err := insertDeploymentCommits(ctx, db, lo.Map(commits, func(commit *github.RepositoryCommit, _ int) *deploymentCommit {
+			return newDeploymentCommit(commit.GetSHA())
+		})); err != nil {
+			return err
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package github
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/utils/transport"
+)
+
+// endpoint is the API of a GitHub instance. The base URLs are empty for github.com.
+type endpoint struct {
+	baseURL    string
+	uploadURL  string
+	graphQLURL string
+	transport  http.RoundTripper
+}
+
+func newEndpoint(source *config.GitHubSource) (*endpoint, error) {
+	roundTripper, err := transport.New(source.CABundlePath)
+	if err != nil {
+		return nil, err
+	}
+	e := &endpoint{transport: roundTripper}
+	if source.BaseURL == "" {
+		return e, nil
+	}
+
+	uploadURL := source.UploadURL
+	if uploadURL == "" {
+		uploadURL = source.BaseURL
+	}
+	client, err := github.NewClient(nil).WithEnterpriseURLs(source.BaseURL, uploadURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse GitHub Enterprise URLs")
+	}
+	e.baseURL = client.BaseURL.String()
+	e.uploadURL = client.UploadURL.String()
+	e.graphQLURL = strings.TrimSuffix(e.baseURL, "v3/") + "graphql"
+	return e, nil
+}
+
+// appsBaseURL is the base URL used to mint GitHub App tokens, ghinstallation expects it without a trailing slash.
+func (e *endpoint) appsBaseURL() string {
+	if e.baseURL == "" {
+		return "https://api.github.com"
+	}
+	return strings.TrimSuffix(e.baseURL, "/")
+}
+
+// host is the API host of the endpoint, e.g. "api.github.com".
+func (e *endpoint) host() string {
+	if e.baseURL == "" {
+		return "api.github.com"
+	}
+	u, _ := url.Parse(e.baseURL) // The URL was validated when the endpoint was created
+	return u.Host
+}
+
+// newHTTPClient returns a client authenticated with token, label names the token in metrics.
+func (e *endpoint) newHTTPClient(token, label string) *http.Client {
+	return &http.Client{
+		Transport: transport.WithTokenLabel(&oauth2.Transport{
+			Base:   e.transport,
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+		}, label),
+	}
+}
+
+func (e *endpoint) newClient(token, label string) *github.Client {
+	return e.withURLs(github.NewClient(e.newHTTPClient(token, label)))
+}
+
+func (e *endpoint) newGraphQLClient(token, label string) *githubv4.Client {
+	httpClient := e.newHTTPClient(token, label)
+	httpClient.Transport = &graphQLRateLimitTransport{base: httpClient.Transport}
+	if e.graphQLURL == "" {
+		return githubv4.NewClient(httpClient)
+	}
+	return githubv4.NewEnterpriseClient(e.graphQLURL, httpClient)
+}
+
+func (e *endpoint) withURLs(client *github.Client) *github.Client {
+	if e.baseURL == "" {
+		return client
+	}
+	// The URLs were validated when the endpoint was created
+	client.BaseURL, _ = url.Parse(e.baseURL)
+	client.UploadURL, _ = url.Parse(e.uploadURL)
+	return client
+}
+
+// repoHost identifies the GitHub instance a repository belongs to, e.g. "github.com".
+func repoHost(repo *github.Repository) string {
+	u, err := url.Parse(repo.GetHTMLURL())
+	if err != nil || u.Host == "" {
+		return "github.com"
+	}
+	return u.Host
+}
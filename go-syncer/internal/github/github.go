@@ -16,7 +16,11 @@ func getEntity[T any](ctx context.Context,
 ) (*T, *github.Response, error) {
 	log := logging.MustFromContext(ctx)
 	log.Info("Getting entity", "entity", fmt.Sprintf("%T", new(T)))
-	client := newRotatableClient(ctx, tokenManager.GetToken())
+	token, label, err := tokenManager.GetToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := newRotatableClient(tokenManager.endpoint, token, label)
 	entity, resp, err := getFunc(ctx, client.Client)
 	if resp != nil {
 		log.V(1).Info("Rate limit remaining", "remaining", resp.Rate.Remaining)
@@ -39,7 +43,11 @@ func listEntities[T any](ctx context.Context,
 ) ([]*T, *github.Response, error) {
 	log := logging.MustFromContext(ctx)
 	log.Info("Listing entities", "entity", fmt.Sprintf("%T", new(T)))
-	client := newRotatableClient(ctx, tokenManager.GetToken())
+	token, label, err := tokenManager.GetToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := newRotatableClient(tokenManager.endpoint, token, label)
 	entities, resp, err := listFunc(ctx, client.Client)
 	if resp != nil {
 		log.V(1).Info("Rate limit remaining", "remaining", resp.Rate.Remaining)
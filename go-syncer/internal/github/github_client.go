@@ -7,36 +7,28 @@ import (
 
 	"github.com/google/go-github/v62/github"
 	"github.com/pkg/errors"
-	"golang.org/x/oauth2"
 
 	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
 )
 
 type RotatableGithubClient struct {
 	*github.Client
+	endpoint *endpoint
 }
 
-func newRotatableClient(ctx context.Context, token string) *RotatableGithubClient {
+func newRotatableClient(endpoint *endpoint, token, label string) *RotatableGithubClient {
 	return &RotatableGithubClient{
-		Client: NewClient(ctx, token),
+		Client:   endpoint.newClient(token, label),
+		endpoint: endpoint,
 	}
 }
 
-func (c *RotatableGithubClient) SetToken(ctx context.Context, token string) {
-	c.Client = NewClient(ctx, token)
-}
-
-func NewClient(ctx context.Context, token string) *github.Client {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	return github.NewClient(tc)
+func (c *RotatableGithubClient) SetToken(token, label string) {
+	c.Client = c.endpoint.newClient(token, label)
 }
 
 func handleRateLimit(ctx context.Context, resp *github.Response, tokenManager *TokenManager) error {
-	log := logging.MustFromContext(ctx)
-
 	if resp.StatusCode != http.StatusForbidden {
 		return errors.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
@@ -46,16 +38,24 @@ func handleRateLimit(ctx context.Context, resp *github.Response, tokenManager *T
 		return errors.New("other than rate limit exceeded forbidden error")
 	}
 
-	log.Info("Rate limit exceeded, rotating token", "reset_time", rateLimit.Reset.String())
+	rotateToken(ctx, tokenManager, rateLimit.Reset.Time)
+	return nil
+}
+
+func rotateToken(ctx context.Context, tokenManager *TokenManager, resetTime time.Time) {
+	log := logging.MustFromContext(ctx)
+
+	log.Info("Rate limit exceeded, rotating token", "reset_time", resetTime.String())
+	host := tokenManager.endpoint.host()
+	metrics.TokenRotations.WithLabelValues(host).Inc()
 	tokenManager.RotateToken()
 	if tokenManager.IsExhausted() {
 		const backoffBuffer = 10 * time.Second
-		backoffDur := rateLimit.Reset.UTC().Sub(time.Now().UTC()) + backoffBuffer
+		backoffDur := resetTime.UTC().Sub(time.Now().UTC()) + backoffBuffer
 		log.Info("All tokens exhausted, applying backoff", "backoff_duration", backoffDur)
+		metrics.BackoffSleeps.WithLabelValues(host).Inc()
+		metrics.BackoffSleepSeconds.WithLabelValues(host).Add(backoffDur.Seconds())
 		tokenManager.WaitForRateLimitReset(backoffDur)
 		tokenManager.ResetExhaustion()
-		return nil
 	}
-
-	return nil
 }
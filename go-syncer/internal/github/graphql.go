@@ -0,0 +1,682 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+type graphQLPageInfo struct {
+	HasNextPage bool
+	EndCursor   githubv4.String
+}
+
+type graphQLRateLimit struct {
+	Remaining int
+	ResetAt   githubv4.DateTime
+}
+
+type graphQLActor struct {
+	Login string
+}
+
+type graphQLNode struct {
+	ID         string
+	DatabaseID int64 `graphql:"databaseId"`
+}
+
+// graphQLUser is an actor with the fields of the user objects of the REST API.
+type graphQLUser struct {
+	Typename  string `graphql:"__typename"`
+	Login     string
+	URL       string      `graphql:"url"`
+	AvatarURL string      `graphql:"avatarUrl"`
+	User      graphQLNode `graphql:"... on User"`
+	Bot       graphQLNode `graphql:"... on Bot"`
+}
+
+// graphQLUserAccount is a user, e.g. an assignee or the account of a git actor, which can't be a bot.
+type graphQLUserAccount struct {
+	graphQLNode
+	Login     string
+	URL       string `graphql:"url"`
+	AvatarURL string `graphql:"avatarUrl"`
+}
+
+type graphQLLabel struct {
+	ID          string
+	Name        string
+	Color       string
+	Description string
+	IsDefault   bool
+}
+
+type graphQLPullRequestReview struct {
+	ID                string
+	DatabaseID        int64 `graphql:"databaseId"`
+	Author            *graphQLUser
+	AuthorAssociation string
+	State             string
+	Body              string
+	URL               string `graphql:"url"`
+	SubmittedAt       *githubv4.DateTime
+	Commit            struct {
+		Oid string
+	}
+}
+
+type graphQLPullRequestReviews struct {
+	Nodes    []graphQLPullRequestReview
+	PageInfo graphQLPageInfo
+}
+
+type graphQLGitActor struct {
+	Name  string
+	Email string
+	Date  githubv4.DateTime
+	User  *graphQLUserAccount
+}
+
+type graphQLPullRequestCommit struct {
+	Commit struct {
+		ID              string
+		Oid             string
+		URL             string `graphql:"url"`
+		Message         string
+		MessageHeadline string
+		Tree            struct {
+			Oid string
+		}
+		Author    graphQLGitActor
+		Committer graphQLGitActor
+	}
+}
+
+type graphQLPullRequestCommits struct {
+	TotalCount int
+	Nodes      []graphQLPullRequestCommit
+	PageInfo   graphQLPageInfo
+}
+
+// graphQLTimelineItemTypes are the GraphQL counterparts of pullRequestEventTypes.
+var graphQLTimelineItemTypes = []githubv4.PullRequestTimelineItemsItemType{
+	githubv4.PullRequestTimelineItemsItemTypeReviewRequestedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeReviewRequestRemovedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeConvertToDraftEvent,
+	githubv4.PullRequestTimelineItemsItemTypeReadyForReviewEvent,
+	githubv4.PullRequestTimelineItemsItemTypeLabeledEvent,
+	githubv4.PullRequestTimelineItemsItemTypeUnlabeledEvent,
+	githubv4.PullRequestTimelineItemsItemTypeAssignedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeUnassignedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeClosedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeReopenedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeMergedEvent,
+	githubv4.PullRequestTimelineItemsItemTypeHeadRefForcePushedEvent,
+}
+
+var graphQLTimelineItemEvents = map[string]string{
+	"ReviewRequestedEvent":      "review_requested",
+	"ReviewRequestRemovedEvent": "review_request_removed",
+	"ConvertToDraftEvent":       "convert_to_draft",
+	"ReadyForReviewEvent":       "ready_for_review",
+	"LabeledEvent":              "labeled",
+	"UnlabeledEvent":            "unlabeled",
+	"AssignedEvent":             "assigned",
+	"UnassignedEvent":           "unassigned",
+	"ClosedEvent":               "closed",
+	"ReopenedEvent":             "reopened",
+	"MergedEvent":               "merged",
+	"HeadRefForcePushedEvent":   "head_ref_force_pushed",
+}
+
+type graphQLTimelineEvent struct {
+	Actor     graphQLActor
+	CreatedAt githubv4.DateTime
+}
+
+type graphQLRequestedReviewer struct {
+	User struct {
+		graphQLNode
+		Login string
+	} `graphql:"... on User"`
+	Bot struct {
+		graphQLNode
+		Login string
+	} `graphql:"... on Bot"`
+	Team struct {
+		graphQLNode
+		Slug string
+		Name string
+	} `graphql:"... on Team"`
+}
+
+type graphQLAssignee struct {
+	User struct {
+		Login string
+	} `graphql:"... on User"`
+	Bot struct {
+		Login string
+	} `graphql:"... on Bot"`
+}
+
+type graphQLTimelineItem struct {
+	Typename             string `graphql:"__typename"`
+	ReviewRequestedEvent struct {
+		graphQLTimelineEvent
+		RequestedReviewer graphQLRequestedReviewer
+	} `graphql:"... on ReviewRequestedEvent"`
+	ReviewRequestRemovedEvent struct {
+		graphQLTimelineEvent
+		RequestedReviewer graphQLRequestedReviewer
+	} `graphql:"... on ReviewRequestRemovedEvent"`
+	ConvertToDraftEvent graphQLTimelineEvent `graphql:"... on ConvertToDraftEvent"`
+	ReadyForReviewEvent graphQLTimelineEvent `graphql:"... on ReadyForReviewEvent"`
+	LabeledEvent        struct {
+		graphQLTimelineEvent
+		Label struct {
+			Name string
+		}
+	} `graphql:"... on LabeledEvent"`
+	UnlabeledEvent struct {
+		graphQLTimelineEvent
+		Label struct {
+			Name string
+		}
+	} `graphql:"... on UnlabeledEvent"`
+	AssignedEvent struct {
+		graphQLTimelineEvent
+		Assignee graphQLAssignee
+	} `graphql:"... on AssignedEvent"`
+	UnassignedEvent struct {
+		graphQLTimelineEvent
+		Assignee graphQLAssignee
+	} `graphql:"... on UnassignedEvent"`
+	ClosedEvent   graphQLTimelineEvent `graphql:"... on ClosedEvent"`
+	ReopenedEvent graphQLTimelineEvent `graphql:"... on ReopenedEvent"`
+	MergedEvent   struct {
+		graphQLTimelineEvent
+		Commit struct {
+			Oid string
+		}
+	} `graphql:"... on MergedEvent"`
+	HeadRefForcePushedEvent struct {
+		graphQLTimelineEvent
+		AfterCommit struct {
+			Oid string
+		}
+	} `graphql:"... on HeadRefForcePushedEvent"`
+}
+
+type graphQLTimelineItems struct {
+	Nodes    []graphQLTimelineItem
+	PageInfo graphQLPageInfo
+}
+
+type graphQLPullRequestFile struct {
+	Path       string
+	Additions  int
+	Deletions  int
+	ChangeType githubv4.PatchStatus
+}
+
+type graphQLPullRequestFiles struct {
+	Nodes    []graphQLPullRequestFile
+	PageInfo graphQLPageInfo
+}
+
+// graphQLPatchStatuses maps GraphQL change types to the REST file statuses.
+var graphQLPatchStatuses = map[githubv4.PatchStatus]string{
+	githubv4.PatchStatusAdded:    "added",
+	githubv4.PatchStatusDeleted:  "removed",
+	githubv4.PatchStatusRenamed:  "renamed",
+	githubv4.PatchStatusCopied:   "copied",
+	githubv4.PatchStatusModified: "modified",
+	githubv4.PatchStatusChanged:  "changed",
+}
+
+type graphQLPullRequest struct {
+	graphQLNode
+	Number              int
+	Title               string
+	Body                string
+	State               githubv4.PullRequestState
+	IsDraft             bool
+	Locked              bool
+	MaintainerCanModify bool
+	AuthorAssociation   string
+	Additions           int
+	Deletions           int
+	ChangedFiles        int
+	URL                 string `graphql:"url"`
+	HeadRefName         string
+	HeadRefOid          string
+	HeadRepository      *struct {
+		graphQLNode
+		Name          string
+		NameWithOwner string
+		Owner         struct {
+			Login string
+		}
+	}
+	MergeCommit *struct {
+		Oid string
+	}
+	BaseRefName string
+	BaseRefOid  string
+	MergedAt    *githubv4.DateTime
+	ClosedAt    *githubv4.DateTime
+	CreatedAt   githubv4.DateTime
+	UpdatedAt   githubv4.DateTime
+	Author      *graphQLUser
+	MergedBy    *graphQLUser
+	Milestone   *struct {
+		graphQLNode
+		Number      int
+		Title       string
+		Description string
+		State       string
+		URL         string `graphql:"url"`
+		DueOn       *githubv4.DateTime
+	}
+	Labels struct {
+		Nodes []graphQLLabel
+	} `graphql:"labels(first: $labelsPerPage)"`
+	Assignees struct {
+		Nodes []graphQLUserAccount
+	} `graphql:"assignees(first: $assigneesPerPage)"`
+	ReviewRequests struct {
+		Nodes []struct {
+			RequestedReviewer graphQLRequestedReviewer
+		}
+	} `graphql:"reviewRequests(first: $reviewRequestsPerPage)"`
+	Comments struct {
+		TotalCount int
+	}
+	TimelineItems graphQLTimelineItems      `graphql:"timelineItems(first: $timelineItemsPerPage, itemTypes: $timelineItemTypes)"`
+	Reviews       graphQLPullRequestReviews `graphql:"reviews(first: $reviewsPerPage)"`
+	Commits       graphQLPullRequestCommits `graphql:"commits(first: $commitsPerPage)"`
+	Files         graphQLPullRequestFiles   `graphql:"files(first: $filesPerPage)"`
+}
+
+// graphQLMaxRateLimitRetries caps how many times a query is retried on rate limits, with another token or after the
+// rate limit resets.
+const graphQLMaxRateLimitRetries = 5
+
+// errGraphQLRateLimited is returned for responses with a RATE_LIMITED error, whose type the GraphQL client drops.
+var errGraphQLRateLimited = errors.New("graphql rate limit exceeded")
+
+func queryGraphQL(ctx context.Context, tokenManager *TokenManager, query interface{}, variables map[string]interface{}) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Querying GraphQL", "query", fmt.Sprintf("%T", query))
+	for retries := 0; ; retries++ {
+		token, label, err := tokenManager.GetToken(ctx)
+		if err != nil {
+			return err
+		}
+		err = tokenManager.endpoint.newGraphQLClient(token, label).Query(ctx, query, variables)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errGraphQLRateLimited) {
+			return errors.Wrap(err, "failed to query graphql")
+		}
+		if retries == graphQLMaxRateLimitRetries {
+			return errors.Wrapf(err, "failed to query graphql after %d retries", retries)
+		}
+		resetTime, err := getGraphQLRateLimitReset(ctx, tokenManager.endpoint, token, label)
+		if err != nil {
+			return err
+		}
+		rotateToken(ctx, tokenManager, resetTime)
+	}
+}
+
+// graphQLRateLimitTransport fails responses with a RATE_LIMITED error with errGraphQLRateLimited, as GitHub answers
+// rate limited queries with 200 OK.
+type graphQLRateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *graphQLRateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read graphql response")
+	}
+	var out struct {
+		Errors []struct {
+			Type string
+		}
+	}
+	if err := json.Unmarshal(body, &out); err == nil {
+		for _, e := range out.Errors {
+			if e.Type == "RATE_LIMITED" {
+				return nil, errGraphQLRateLimited
+			}
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// getGraphQLRateLimitReset uses the REST rate limit endpoint, which doesn't count against the rate limit.
+func getGraphQLRateLimitReset(ctx context.Context, endpoint *endpoint, token, label string) (time.Time, error) {
+	rateLimits, _, err := endpoint.newClient(token, label).RateLimit.Get(ctx)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get rate limits")
+	}
+	return rateLimits.GetGraphQL().Reset.Time, nil
+}
+
+// newPullRequestFromGraphQL converts a GraphQL pull request to the shape of the REST API's, so the data column is the
+// same with either backend. The API URLs are derived from the repository's, as GraphQL only has the HTML URL.
+func newPullRequestFromGraphQL(repo *github.Repository, pr *graphQLPullRequest) *pullRequest {
+	apiURL := fmt.Sprintf("%s/pulls/%d", repo.GetURL(), pr.Number)
+	issueURL := fmt.Sprintf("%s/issues/%d", repo.GetURL(), pr.Number)
+	data := &github.PullRequest{
+		ID:                  github.Int64(pr.DatabaseID),
+		NodeID:              github.String(pr.ID),
+		Number:              github.Int(pr.Number),
+		Title:               github.String(pr.Title),
+		State:               github.String(graphQLPullRequestStateToREST(pr.State)),
+		Draft:               github.Bool(pr.IsDraft),
+		Locked:              github.Bool(pr.Locked),
+		MaintainerCanModify: github.Bool(pr.MaintainerCanModify),
+		AuthorAssociation:   github.String(pr.AuthorAssociation),
+		Merged:              github.Bool(pr.State == githubv4.PullRequestStateMerged),
+		Additions:           github.Int(pr.Additions),
+		Deletions:           github.Int(pr.Deletions),
+		ChangedFiles:        github.Int(pr.ChangedFiles),
+		Commits:             github.Int(pr.Commits.TotalCount),
+		Comments:            github.Int(pr.Comments.TotalCount),
+		URL:                 github.String(apiURL),
+		HTMLURL:             github.String(pr.URL),
+		DiffURL:             github.String(pr.URL + ".diff"),
+		PatchURL:            github.String(pr.URL + ".patch"),
+		IssueURL:            github.String(issueURL),
+		CommitsURL:          github.String(apiURL + "/commits"),
+		ReviewCommentsURL:   github.String(apiURL + "/comments"),
+		CommentsURL:         github.String(issueURL + "/comments"),
+		StatusesURL:         github.String(fmt.Sprintf("%s/statuses/%s", repo.GetURL(), pr.HeadRefOid)),
+		User:                newUserFromGraphQL(pr.Author),
+		MergedBy:            newUserFromGraphQL(pr.MergedBy),
+		Head: &github.PullRequestBranch{
+			Ref: github.String(pr.HeadRefName),
+			SHA: github.String(pr.HeadRefOid),
+		},
+		Base: &github.PullRequestBranch{
+			Label: github.String(repo.GetOwner().GetLogin() + ":" + pr.BaseRefName),
+			Ref:   github.String(pr.BaseRefName),
+			SHA:   github.String(pr.BaseRefOid),
+			Repo:  repo,
+			User:  repo.GetOwner(),
+		},
+		Labels:             make([]*github.Label, 0, len(pr.Labels.Nodes)),
+		Assignees:          make([]*github.User, 0, len(pr.Assignees.Nodes)),
+		RequestedReviewers: []*github.User{},
+		RequestedTeams:     []*github.Team{},
+		CreatedAt:          &github.Timestamp{Time: pr.CreatedAt.Time},
+		UpdatedAt:          &github.Timestamp{Time: pr.UpdatedAt.Time},
+	}
+	if head := pr.HeadRepository; head != nil { // The head repository is null when the fork was deleted
+		data.Head.Label = github.String(head.Owner.Login + ":" + pr.HeadRefName)
+		data.Head.User = &github.User{Login: github.String(head.Owner.Login)}
+		data.Head.Repo = &github.Repository{
+			ID:       github.Int64(head.DatabaseID),
+			NodeID:   github.String(head.ID),
+			Name:     github.String(head.Name),
+			FullName: github.String(head.NameWithOwner),
+			Owner:    data.Head.User,
+		}
+	}
+	if pr.Body != "" { // REST returns a null body for pull requests without a description
+		data.Body = github.String(pr.Body)
+	}
+	if pr.MergedAt != nil {
+		data.MergedAt = &github.Timestamp{Time: pr.MergedAt.Time}
+	}
+	if pr.MergeCommit != nil {
+		data.MergeCommitSHA = github.String(pr.MergeCommit.Oid)
+	}
+	if pr.ClosedAt != nil {
+		data.ClosedAt = &github.Timestamp{Time: pr.ClosedAt.Time}
+	}
+	if m := pr.Milestone; m != nil {
+		data.Milestone = &github.Milestone{
+			ID:          github.Int64(m.DatabaseID),
+			NodeID:      github.String(m.ID),
+			Number:      github.Int(m.Number),
+			Title:       github.String(m.Title),
+			Description: github.String(m.Description),
+			State:       github.String(strings.ToLower(m.State)),
+			HTMLURL:     github.String(m.URL),
+		}
+		if m.DueOn != nil {
+			data.Milestone.DueOn = &github.Timestamp{Time: m.DueOn.Time}
+		}
+	}
+	for _, label := range pr.Labels.Nodes {
+		data.Labels = append(data.Labels, &github.Label{
+			NodeID:      github.String(label.ID),
+			Name:        github.String(label.Name),
+			Color:       github.String(label.Color),
+			Description: github.String(label.Description),
+			Default:     github.Bool(label.IsDefault),
+		})
+	}
+	for i := range pr.Assignees.Nodes {
+		data.Assignees = append(data.Assignees, newUserAccountFromGraphQL(&pr.Assignees.Nodes[i]))
+	}
+	if len(data.Assignees) > 0 {
+		data.Assignee = data.Assignees[0]
+	}
+	for _, request := range pr.ReviewRequests.Nodes {
+		reviewer := &request.RequestedReviewer
+		switch {
+		case reviewer.Team.Slug != "":
+			data.RequestedTeams = append(data.RequestedTeams, &github.Team{
+				ID:     github.Int64(reviewer.Team.DatabaseID),
+				NodeID: github.String(reviewer.Team.ID),
+				Slug:   github.String(reviewer.Team.Slug),
+				Name:   github.String(reviewer.Team.Name),
+			})
+		case reviewer.User.Login != "":
+			data.RequestedReviewers = append(data.RequestedReviewers, &github.User{
+				ID:     github.Int64(reviewer.User.DatabaseID),
+				NodeID: github.String(reviewer.User.ID),
+				Login:  github.String(reviewer.User.Login),
+				Type:   github.String("User"),
+			})
+		case reviewer.Bot.Login != "":
+			data.RequestedReviewers = append(data.RequestedReviewers, &github.User{
+				ID:     github.Int64(reviewer.Bot.DatabaseID),
+				NodeID: github.String(reviewer.Bot.ID),
+				Login:  github.String(reviewer.Bot.Login),
+				Type:   github.String("Bot"),
+			})
+		}
+	}
+
+	pullRequest := newPullRequest(repo, data)
+	pullRequest.Additions, pullRequest.Deletions, pullRequest.ChangedFiles = pr.Additions, pr.Deletions, pr.ChangedFiles
+	return pullRequest
+}
+
+// newUserFromGraphQL converts an actor, which is null for deleted accounts.
+func newUserFromGraphQL(user *graphQLUser) *github.User {
+	if user == nil {
+		return nil
+	}
+	node := user.User
+	if user.Typename == "Bot" {
+		node = user.Bot
+	}
+	return &github.User{
+		ID:        github.Int64(node.DatabaseID),
+		NodeID:    github.String(node.ID),
+		Login:     github.String(user.Login),
+		Type:      github.String(user.Typename),
+		HTMLURL:   github.String(user.URL),
+		AvatarURL: github.String(user.AvatarURL),
+	}
+}
+
+// newUserAccountFromGraphQL converts a user account, which is null for git actors whose email isn't linked to one.
+func newUserAccountFromGraphQL(user *graphQLUserAccount) *github.User {
+	if user == nil {
+		return nil
+	}
+	return &github.User{
+		ID:        github.Int64(user.DatabaseID),
+		NodeID:    github.String(user.ID),
+		Login:     github.String(user.Login),
+		Type:      github.String("User"),
+		HTMLURL:   github.String(user.URL),
+		AvatarURL: github.String(user.AvatarURL),
+	}
+}
+
+func newPullRequestReviewFromGraphQL(repo *github.Repository, pr *pullRequest, review *graphQLPullRequestReview) *pullRequestReview {
+	data := &github.PullRequestReview{
+		ID:                github.Int64(review.DatabaseID),
+		NodeID:            github.String(review.ID),
+		User:              newUserFromGraphQL(review.Author),
+		Body:              github.String(review.Body),
+		State:             github.String(review.State),
+		HTMLURL:           github.String(review.URL),
+		PullRequestURL:    github.String(fmt.Sprintf("%s/pulls/%d", repo.GetURL(), pr.Number)),
+		CommitID:          github.String(review.Commit.Oid),
+		AuthorAssociation: github.String(review.AuthorAssociation),
+	}
+	if review.SubmittedAt != nil {
+		data.SubmittedAt = &github.Timestamp{Time: review.SubmittedAt.Time}
+	}
+	return newPullRequestReview(repo, pr.PrID, data)
+}
+
+// newPullRequestCommitFromGraphQL converts a GraphQL commit. The API URLs are derived from the repository's, as
+// GraphQL only has the HTML URL. Parents aren't queried, as a connection per commit would multiply the query's cost.
+func newPullRequestCommitFromGraphQL(repo *github.Repository, prID int, prCommit *graphQLPullRequestCommit) *pullRequestCommit {
+	commit := prCommit.Commit
+	apiURL := fmt.Sprintf("%s/commits/%s", repo.GetURL(), commit.Oid)
+	data := &github.RepositoryCommit{
+		SHA:    github.String(commit.Oid),
+		NodeID: github.String(commit.ID),
+		Commit: &github.Commit{
+			Message: github.String(commit.Message),
+			Author: &github.CommitAuthor{
+				Name:  github.String(commit.Author.Name),
+				Email: github.String(commit.Author.Email),
+				Date:  &github.Timestamp{Time: commit.Author.Date.Time},
+			},
+			Committer: &github.CommitAuthor{
+				Name:  github.String(commit.Committer.Name),
+				Email: github.String(commit.Committer.Email),
+				Date:  &github.Timestamp{Time: commit.Committer.Date.Time},
+			},
+			Tree: &github.Tree{SHA: github.String(commit.Tree.Oid)},
+			URL:  github.String(fmt.Sprintf("%s/git/commits/%s", repo.GetURL(), commit.Oid)),
+		},
+		Author:      newUserAccountFromGraphQL(commit.Author.User),
+		Committer:   newUserAccountFromGraphQL(commit.Committer.User),
+		URL:         github.String(apiURL),
+		HTMLURL:     github.String(commit.URL),
+		CommentsURL: github.String(apiURL + "/comments"),
+	}
+	return newPullRequestCommit(repo, prID, data)
+}
+
+// newPullRequestFileFromGraphQL converts a GraphQL file, which has no previous filename for renames. The files of pull
+// requests with renames are listed with REST instead.
+func newPullRequestFileFromGraphQL(repo *github.Repository, prID int, file *graphQLPullRequestFile) *pullRequestFile {
+	return newPullRequestFile(repo, prID, &github.CommitFile{
+		Filename:  github.String(file.Path),
+		Status:    github.String(graphQLPatchStatuses[file.ChangeType]),
+		Additions: github.Int(file.Additions),
+		Deletions: github.Int(file.Deletions),
+		Changes:   github.Int(file.Additions + file.Deletions),
+	})
+}
+
+func newPullRequestEventFromGraphQL(repo *github.Repository, prID int, item *graphQLTimelineItem) *pullRequestEvent {
+	var event graphQLTimelineEvent
+	data := &github.IssueEvent{Event: github.String(graphQLTimelineItemEvents[item.Typename])}
+	switch item.Typename {
+	case "ReviewRequestedEvent":
+		event = item.ReviewRequestedEvent.graphQLTimelineEvent
+		setRequestedReviewerFromGraphQL(data, &item.ReviewRequestedEvent.RequestedReviewer)
+	case "ReviewRequestRemovedEvent":
+		event = item.ReviewRequestRemovedEvent.graphQLTimelineEvent
+		setRequestedReviewerFromGraphQL(data, &item.ReviewRequestRemovedEvent.RequestedReviewer)
+	case "ConvertToDraftEvent":
+		event = item.ConvertToDraftEvent
+	case "ReadyForReviewEvent":
+		event = item.ReadyForReviewEvent
+	case "LabeledEvent":
+		event = item.LabeledEvent.graphQLTimelineEvent
+		data.Label = &github.Label{Name: github.String(item.LabeledEvent.Label.Name)}
+	case "UnlabeledEvent":
+		event = item.UnlabeledEvent.graphQLTimelineEvent
+		data.Label = &github.Label{Name: github.String(item.UnlabeledEvent.Label.Name)}
+	case "AssignedEvent":
+		event = item.AssignedEvent.graphQLTimelineEvent
+		data.Assignee = newAssigneeFromGraphQL(&item.AssignedEvent.Assignee)
+	case "UnassignedEvent":
+		event = item.UnassignedEvent.graphQLTimelineEvent
+		data.Assignee = newAssigneeFromGraphQL(&item.UnassignedEvent.Assignee)
+	case "ClosedEvent":
+		event = item.ClosedEvent
+	case "ReopenedEvent":
+		event = item.ReopenedEvent
+	case "MergedEvent":
+		event = item.MergedEvent.graphQLTimelineEvent
+		data.CommitID = github.String(item.MergedEvent.Commit.Oid)
+	case "HeadRefForcePushedEvent":
+		event = item.HeadRefForcePushedEvent.graphQLTimelineEvent
+		data.CommitID = github.String(item.HeadRefForcePushedEvent.AfterCommit.Oid)
+	}
+	data.Actor = &github.User{Login: github.String(event.Actor.Login)}
+	data.CreatedAt = &github.Timestamp{Time: event.CreatedAt.Time}
+	return newPullRequestEvent(repo, prID, data)
+}
+
+func setRequestedReviewerFromGraphQL(data *github.IssueEvent, reviewer *graphQLRequestedReviewer) {
+	switch {
+	case reviewer.Team.Slug != "":
+		data.RequestedTeam = &github.Team{Slug: github.String(reviewer.Team.Slug)}
+	case reviewer.User.Login != "":
+		data.RequestedReviewer = &github.User{Login: github.String(reviewer.User.Login)}
+	case reviewer.Bot.Login != "":
+		data.RequestedReviewer = &github.User{Login: github.String(reviewer.Bot.Login)}
+	}
+}
+
+func newAssigneeFromGraphQL(assignee *graphQLAssignee) *github.User {
+	if assignee.User.Login != "" {
+		return &github.User{Login: github.String(assignee.User.Login)}
+	}
+	return &github.User{Login: github.String(assignee.Bot.Login)}
+}
+
+// graphQLPullRequestStateToREST maps GraphQL states to the REST ones, where merged pull requests are "closed".
+func graphQLPullRequestStateToREST(state githubv4.PullRequestState) string {
+	if state == githubv4.PullRequestStateOpen {
+		return "open"
+	}
+	return "closed"
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+)
+
+const (
+	issuesPerPage = 100
+	// issueLinkOverlap is how far before the previous run pull requests are linked to issues again
+	issueLinkOverlap = 5 * time.Minute
+)
+
+// syncRepoIssues syncs the issues updated since the last sync, oldest first, then links pull requests to the issues
+// they close.
+func syncRepoIssues(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing issues")
+
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityIssues)
+	if err != nil {
+		return err
+	}
+	since := time.Now().UTC().Add(-syncBackTime)
+	if lastSynced != nil {
+		log.Info("Last synced time found, syncing from last sync time", "last_synced", lastSynced)
+		since = *lastSynced
+	}
+
+	opt := &github.IssueListByRepoOptions{
+		ListOptions: github.ListOptions{PerPage: issuesPerPage},
+		State:       "all",
+		Sort:        "updated",
+		Direction:   "asc",
+		Since:       since,
+	}
+	for {
+		issues, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.Issue, *github.Response, error) {
+				return client.Issues.ListByRepo(ctx, *repo.Owner.Login, *repo.Name, opt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list issues")
+		}
+		if len(issues) == 0 {
+			break
+		}
+
+		// The issues API lists pull requests as well, they are synced on their own
+		issuesToSync := lo.Filter(issues, func(i *github.Issue, _ int) bool {
+			return !i.IsPullRequest()
+		})
+		if err := upsertIssues(ctx, db, lo.Map(issuesToSync, func(i *github.Issue, _ int) *issue {
+			return newIssue(repo, i)
+		})); err != nil {
+			return errors.Wrap(err, "failed to insert issues")
+		}
+		lastUpdatedAt := issues[len(issues)-1].GetUpdatedAt().Time
+		log.Info("Synced issues", "issues", len(issuesToSync), "page", opt.Page, "last_issue_updated_at", lastUpdatedAt)
+
+		if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityIssues, lastUpdatedAt); err != nil {
+			return errors.Wrap(err, "failed to update last synced time")
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	if err := linkPullRequestIssues(ctx, db, repoHost(repo), repo.GetFullName()); err != nil {
+		return err
+	}
+
+	log.Info("Synced issues")
+	return nil
+}
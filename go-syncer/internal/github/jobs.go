@@ -0,0 +1,226 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/jobs"
+	"github.com/ilaif/athena-cycle/syncer/internal/locks"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+const (
+	JobKindRepoSync     = "repo_sync"
+	JobKindPREnrichment = "pr_enrichment"
+	JobKindBackfill     = "backfill"
+
+	// lockedRetryDelay is how long a job waits for a repository that's being synced by another job
+	lockedRetryDelay = 30 * time.Second
+)
+
+// jobPayload is the repository a job is for, and the pull request for pull request enrichment.
+type jobPayload struct {
+	Source string `json:"source"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number,omitempty"`
+}
+
+// jobTarget is the repository of a job, resolved from its payload.
+type jobTarget struct {
+	source       *Source
+	tokenManager *TokenManager
+	repo         *github.Repository
+	number       int
+	runID        int64
+}
+
+// enqueueJob queues a job for a repository. Its dedup key keeps a repository, or a pull request, from being queued
+// again while it's waiting for a worker.
+func enqueueJob(ctx context.Context, queue *jobs.Queue, kind string, payload jobPayload) error {
+	return queue.Enqueue(ctx, kind, jobDedupKey(kind, payload), payload) //nolint:wrapcheck
+}
+
+// EnqueueBackfill queues a backfill job for a repository of a source, which is run by the workers of the syncer.
+func EnqueueBackfill(ctx context.Context, queue *jobs.Queue, source string, repo config.GitHubRepository) error {
+	return enqueueJob(ctx, queue, JobKindBackfill, jobPayload{Source: source, Repo: string(repo)})
+}
+
+// enqueueRunJob queues a job for the run of the context to wait for. A repository that's still queued from a previous
+// run isn't queued again, the run waits for the queued job instead, which records the repository in the latest run.
+func enqueueRunJob(ctx context.Context, db *sqlx.DB, queue *jobs.Queue, kind string, payload jobPayload) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	jobID, err := queue.EnqueueTx(ctx, tx, kind, jobDedupKey(kind, payload), payload)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if err := syncrun.AddJob(ctx, tx, jobID); err != nil {
+		return err //nolint:wrapcheck
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit transaction")
+}
+
+func jobDedupKey(kind string, payload jobPayload) string {
+	dedupKey := fmt.Sprintf("%s:%s/%s", kind, payload.Source, payload.Repo)
+	if payload.Number != 0 {
+		dedupKey += fmt.Sprintf("#%d", payload.Number)
+	}
+	return dedupKey
+}
+
+// RegisterJobHandlers registers the handlers of the GitHub jobs, a job is run with the source named in its payload.
+func RegisterJobHandlers(worker *jobs.Worker, db *sqlx.DB, sources []*Source) {
+	h := &jobHandlers{db: db, sources: lo.KeyBy(sources, func(s *Source) string { return s.Name() })}
+	worker.Handle(JobKindRepoSync, h.syncRepo)
+	worker.Handle(JobKindPREnrichment, h.enrichPullRequest)
+	worker.Handle(JobKindBackfill, h.backfill)
+}
+
+type jobHandlers struct {
+	db      *sqlx.DB
+	sources map[string]*Source
+}
+
+// syncRepo syncs a repository queued by the sync of its source.
+func (h *jobHandlers) syncRepo(ctx context.Context, job *jobs.Job) error {
+	target, err := h.resolve(ctx, job)
+	if err != nil {
+		return err
+	}
+	return h.syncLocked(ctx, target, func(ctx context.Context, repoRun *syncrun.Repo) {
+		syncRepo(ctx, h.db, target.source.config, target.source.credentials, target.repo, repoRun)
+	})
+}
+
+// enrichPullRequest syncs a pull request a webhook was delivered for. The pull request is fetched again, so a retried
+// job syncs its latest state.
+func (h *jobHandlers) enrichPullRequest(ctx context.Context, job *jobs.Job) error {
+	target, err := h.resolve(ctx, job)
+	if err != nil {
+		return err
+	}
+	log := logging.MustFromContext(ctx).WithValues("repo", target.repo.GetFullName(), "pr", target.number)
+	ctx = logging.NewContext(ctx, log)
+
+	pr, _, err := getEntity(ctx, target.tokenManager,
+		func(ctx context.Context, client *github.Client) (*github.PullRequest, *github.Response, error) {
+			return client.PullRequests.Get(ctx, target.repo.GetOwner().GetLogin(), target.repo.GetName(), target.number)
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to get pull request")
+	}
+	if _, err := syncPullRequestsChunk(ctx, h.db, target.tokenManager, target.repo, []*github.PullRequest{pr}); err != nil {
+		return errors.Wrap(err, "failed to sync pull request")
+	}
+	return nil
+}
+
+// backfill syncs the pull requests of a repository again from the start of the sync window, e.g. after a repository
+// was added or its pull requests were deleted. The watermark is only cleared on the first attempt, so a retry resumes
+// from where the failed attempt stopped.
+func (h *jobHandlers) backfill(ctx context.Context, job *jobs.Job) error {
+	target, err := h.resolve(ctx, job)
+	if err != nil {
+		return err
+	}
+	return h.syncLocked(ctx, target, func(ctx context.Context, repoRun *syncrun.Repo) {
+		log := logging.MustFromContext(ctx)
+		log.Info("Backfilling pull requests")
+		if job.Attempts == 1 {
+			err := pg.DeleteLastSyncAt(ctx, h.db, repoHost(target.repo), target.repo.GetFullName(), pg.SyncEntityPullRequests)
+			if err != nil {
+				repoRun.Fail(err)
+				return
+			}
+		}
+		syncPullRequests := pullRequestSyncer(target.source.config)
+		if err := syncPullRequests(ctx, h.db, target.tokenManager, target.repo); err != nil {
+			log.Error(err, "Failed to backfill pull requests")
+			repoRun.Fail(errors.Wrap(err, "failed to sync pull requests"))
+			return
+		}
+		log.Info("Backfilled pull requests")
+	})
+}
+
+// syncLocked syncs a repository while holding its advisory lock. A repository that's being synced by another worker
+// queues the job again shortly, without counting the attempt, so it runs after the other sync.
+// The repository is recorded in the latest run of the source that waits for the job, a job queued by hand gets its own
+// run.
+func (h *jobHandlers) syncLocked(ctx context.Context, target *jobTarget,
+	sync func(ctx context.Context, repoRun *syncrun.Repo),
+) error {
+	repo := target.repo
+	log := logging.MustFromContext(ctx).WithValues("repo", repo.GetFullName(), "host", repoHost(repo))
+	lock, locked, err := locks.TryLock(ctx, h.db, locks.RepoKey(repoHost(repo), repo.GetFullName()))
+	if err != nil {
+		return errors.Wrap(err, "failed to lock repository")
+	}
+	if !locked {
+		return jobs.RetryLater(lockedRetryDelay, "repository is being synced by another worker")
+	}
+	defer lock.Release(ctx)
+
+	var run *syncrun.Run
+	if target.runID != 0 {
+		ctx, run = syncrun.Attach(ctx, h.db, target.source.Name(), target.runID)
+	} else {
+		ctx, run = syncrun.Start(ctx, h.db, target.source.Name())
+	}
+	repoCtx, repoRun := syncrun.StartRepo(logging.NewContext(ctx, log), repoHost(repo), repo.GetFullName())
+	sync(repoCtx, repoRun)
+	repoRun.Finish(repoCtx)
+	run.Finish(ctx, repoRun.Err())
+	return repoRun.Err()
+}
+
+// resolve decodes the payload of a job and gets the repository it's for with the credentials of its source.
+func (h *jobHandlers) resolve(ctx context.Context, job *jobs.Job) (*jobTarget, error) {
+	var payload jobPayload
+	if err := job.Decode(&payload); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	source, ok := h.sources[payload.Source]
+	if !ok {
+		return nil, errors.Errorf("unknown GitHub source %s", payload.Source)
+	}
+	owner, name, _ := strings.Cut(payload.Repo, "/")
+	tokenManager, err := source.credentials.ForOwner(ctx, owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get credentials")
+	}
+	repo, _, err := getEntity(ctx, tokenManager,
+		func(ctx context.Context, client *github.Client) (*github.Repository, *github.Response, error) {
+			return client.Repositories.Get(ctx, owner, name)
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get repository")
+	}
+	runID, err := syncrun.JobRunID(ctx, h.db, job.ID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &jobTarget{
+		source:       source,
+		tokenManager: tokenManager,
+		repo:         repo,
+		number:       payload.Number,
+		runID:        runID,
+	}, nil
+}
@@ -1,12 +1,17 @@
 package github
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v62/github"
+	"github.com/samber/lo"
 )
 
+const provider = "github"
+
 type pullRequest struct {
+	Host                 string              `db:"host"`
 	PrID                 int                 `db:"pr_id"`
 	RepoID               int                 `db:"repo_id"`
 	Repo                 string              `db:"repo"`
@@ -23,10 +28,18 @@ type pullRequest struct {
 	CreatedAt            time.Time           `db:"created_at"`
 	UpdatedAt            time.Time           `db:"updated_at"`
 	LastReadyForReviewAt *time.Time          `db:"last_ready_for_review_at"`
+	FirstCommitAt        *time.Time          `db:"first_commit_at"`
+	HeadSHA              string              `db:"head_sha"`
+	HeadRef              string              `db:"head_ref"`
+	CIState              *string             `db:"ci_state"`
+	CIStartedAt          *time.Time          `db:"ci_started_at"`
+	CICompletedAt        *time.Time          `db:"ci_completed_at"`
+	MergeCommitSHA       *string             `db:"merge_commit_sha"`
 	Data                 *github.PullRequest `db:"data"`
 }
 
 type pullRequestReview struct {
+	Host        string                    `db:"host"`
 	ReviewID    int                       `db:"review_id"`
 	PrID        int                       `db:"pr_id"`
 	Repo        string                    `db:"repo"`
@@ -36,3 +49,663 @@ type pullRequestReview struct {
 	CommitID    string                    `db:"commit_id"`
 	Data        *github.PullRequestReview `db:"data"`
 }
+
+type pullRequestCommit struct {
+	Host            string                   `db:"host"`
+	PrID            int                      `db:"pr_id"`
+	Repo            string                   `db:"repo"`
+	SHA             string                   `db:"sha"`
+	AuthorLogin     string                   `db:"author_login"`
+	AuthorEmail     string                   `db:"author_email"`
+	CommitterLogin  string                   `db:"committer_login"`
+	CommitterEmail  string                   `db:"committer_email"`
+	AuthoredAt      time.Time                `db:"authored_at"`
+	CommittedAt     time.Time                `db:"committed_at"`
+	MessageHeadline string                   `db:"message_headline"`
+	Data            *github.RepositoryCommit `db:"data"`
+}
+
+type pullRequestReviewComment struct {
+	Host         string                     `db:"host"`
+	CommentID    int                        `db:"comment_id"`
+	PrID         int                        `db:"pr_id"`
+	ReviewID     *int                       `db:"review_id"`
+	Repo         string                     `db:"repo"`
+	Username     string                     `db:"username"`
+	Path         string                     `db:"path"`
+	Line         *int                       `db:"line"`
+	OriginalLine *int                       `db:"original_line"`
+	StartLine    *int                       `db:"start_line"`
+	Side         *string                    `db:"side"`
+	InReplyTo    *int                       `db:"in_reply_to"`
+	DiffHunk     string                     `db:"diff_hunk"`
+	CommitID     string                     `db:"commit_id"`
+	Body         string                     `db:"body"`
+	CreatedAt    time.Time                  `db:"created_at"`
+	UpdatedAt    time.Time                  `db:"updated_at"`
+	Data         *github.PullRequestComment `db:"data"`
+}
+
+type pullRequestComment struct {
+	Host      string               `db:"host"`
+	CommentID int                  `db:"comment_id"`
+	PrID      int                  `db:"pr_id"`
+	Repo      string               `db:"repo"`
+	Username  string               `db:"username"`
+	Body      string               `db:"body"`
+	CreatedAt time.Time            `db:"created_at"`
+	UpdatedAt time.Time            `db:"updated_at"`
+	Data      *github.IssueComment `db:"data"`
+}
+
+// pullRequestFile is a file changed by a pull request. The patch isn't stored as it can be arbitrarily large.
+type pullRequestFile struct {
+	Host             string  `db:"host"`
+	PrID             int     `db:"pr_id"`
+	Repo             string  `db:"repo"`
+	Filename         string  `db:"filename"`
+	Status           string  `db:"status"`
+	Additions        int     `db:"additions"`
+	Deletions        int     `db:"deletions"`
+	Changes          int     `db:"changes"`
+	PreviousFilename *string `db:"previous_filename"`
+	SHA              *string `db:"sha"`
+}
+
+type pullRequestCheckRun struct {
+	Host        string           `db:"host"`
+	CheckRunID  int              `db:"check_run_id"`
+	PrID        int              `db:"pr_id"`
+	Repo        string           `db:"repo"`
+	HeadSHA     string           `db:"head_sha"`
+	Name        string           `db:"name"`
+	App         string           `db:"app"`
+	Status      string           `db:"status"`
+	Conclusion  *string          `db:"conclusion"`
+	StartedAt   *time.Time       `db:"started_at"`
+	CompletedAt *time.Time       `db:"completed_at"`
+	Data        *github.CheckRun `db:"data"`
+}
+
+type pullRequestCommitStatus struct {
+	Host        string             `db:"host"`
+	StatusID    int                `db:"status_id"`
+	PrID        int                `db:"pr_id"`
+	Repo        string             `db:"repo"`
+	HeadSHA     string             `db:"head_sha"`
+	Context     string             `db:"context"`
+	State       string             `db:"state"`
+	Description *string            `db:"description"`
+	TargetURL   *string            `db:"target_url"`
+	Creator     string             `db:"creator"`
+	CreatedAt   time.Time          `db:"created_at"`
+	UpdatedAt   time.Time          `db:"updated_at"`
+	Data        *github.RepoStatus `db:"data"`
+}
+
+// pullRequestEventTypes are the timeline events persisted in pull_request_events.
+var pullRequestEventTypes = []string{
+	"review_requested", "review_request_removed", "convert_to_draft", "ready_for_review",
+	"labeled", "unlabeled", "assigned", "unassigned", "closed", "reopened", "merged", "head_ref_force_pushed",
+}
+
+type pullRequestEvent struct {
+	Host              string             `db:"host"`
+	EventID           *int               `db:"event_id"`
+	PrID              int                `db:"pr_id"`
+	Repo              string             `db:"repo"`
+	Event             string             `db:"event"`
+	Actor             string             `db:"actor"`
+	CreatedAt         time.Time          `db:"created_at"`
+	RequestedReviewer *string            `db:"requested_reviewer"`
+	RequestedTeam     *string            `db:"requested_team"`
+	Label             *string            `db:"label"`
+	Assignee          *string            `db:"assignee"`
+	CommitID          *string            `db:"commit_id"`
+	Data              *github.IssueEvent `db:"data"`
+}
+
+type workflowRun struct {
+	Host         string              `db:"host"`
+	RunID        int                 `db:"run_id"`
+	Repo         string              `db:"repo"`
+	WorkflowID   int                 `db:"workflow_id"`
+	Name         string              `db:"name"`
+	Event        string              `db:"event"`
+	Status       string              `db:"status"`
+	Conclusion   *string             `db:"conclusion"`
+	HeadBranch   string              `db:"head_branch"`
+	HeadSHA      string              `db:"head_sha"`
+	RunNumber    int                 `db:"run_number"`
+	RunAttempt   int                 `db:"run_attempt"`
+	Actor        string              `db:"actor"`
+	PrIDs        []int64             `db:"pr_ids"`
+	PrNumbers    []int               `db:"pr_numbers"`
+	CreatedAt    time.Time           `db:"created_at"`
+	RunStartedAt *time.Time          `db:"run_started_at"`
+	UpdatedAt    time.Time           `db:"updated_at"`
+	Data         *github.WorkflowRun `db:"data"`
+}
+
+type workflowJob struct {
+	Host        string              `db:"host"`
+	JobID       int                 `db:"job_id"`
+	RunID       int                 `db:"run_id"`
+	RunAttempt  int                 `db:"run_attempt"`
+	Repo        string              `db:"repo"`
+	Name        string              `db:"name"`
+	Status      string              `db:"status"`
+	Conclusion  *string             `db:"conclusion"`
+	HeadSHA     string              `db:"head_sha"`
+	RunnerName  *string             `db:"runner_name"`
+	CreatedAt   *time.Time          `db:"created_at"`
+	StartedAt   *time.Time          `db:"started_at"`
+	CompletedAt *time.Time          `db:"completed_at"`
+	Data        *github.WorkflowJob `db:"data"`
+}
+
+type deployment struct {
+	Host         string             `db:"host"`
+	DeploymentID int                `db:"deployment_id"`
+	Repo         string             `db:"repo"`
+	Environment  string             `db:"environment"`
+	SHA          string             `db:"sha"`
+	Ref          string             `db:"ref"`
+	Task         string             `db:"task"`
+	Creator      string             `db:"creator"`
+	State        *string            `db:"state"`
+	CreatedAt    time.Time          `db:"created_at"`
+	UpdatedAt    time.Time          `db:"updated_at"`
+	SucceededAt  *time.Time         `db:"succeeded_at"`
+	FailedAt     *time.Time         `db:"failed_at"`
+	Data         *github.Deployment `db:"data"`
+}
+
+type deploymentStatus struct {
+	Host         string                   `db:"host"`
+	StatusID     int                      `db:"status_id"`
+	DeploymentID int                      `db:"deployment_id"`
+	Repo         string                   `db:"repo"`
+	State        string                   `db:"state"`
+	Description  *string                  `db:"description"`
+	Creator      string                   `db:"creator"`
+	CreatedAt    time.Time                `db:"created_at"`
+	Data         *github.DeploymentStatus `db:"data"`
+}
+
+type deploymentCommit struct {
+	Host         string `db:"host"`
+	DeploymentID int    `db:"deployment_id"`
+	Repo         string `db:"repo"`
+	SHA          string `db:"sha"`
+}
+
+type release struct {
+	Host            string                    `db:"host"`
+	ReleaseID       int                       `db:"release_id"`
+	Repo            string                    `db:"repo"`
+	TagName         string                    `db:"tag_name"`
+	Name            string                    `db:"name"`
+	Draft           bool                      `db:"draft"`
+	Prerelease      bool                      `db:"prerelease"`
+	TargetCommitish string                    `db:"target_commitish"`
+	Author          string                    `db:"author"`
+	CreatedAt       time.Time                 `db:"created_at"`
+	PublishedAt     *time.Time                `db:"published_at"`
+	Data            *github.RepositoryRelease `db:"data"`
+}
+
+type tag struct {
+	Host     string    `db:"host"`
+	Repo     string    `db:"repo"`
+	Name     string    `db:"name"`
+	SHA      string    `db:"sha"`
+	TaggedAt time.Time `db:"tagged_at"`
+}
+
+type tagCommit struct {
+	Host string `db:"host"`
+	Repo string `db:"repo"`
+	Tag  string `db:"tag"`
+	SHA  string `db:"sha"`
+}
+
+type issue struct {
+	Host        string        `db:"host"`
+	IssueID     int           `db:"issue_id"`
+	Repo        string        `db:"repo"`
+	Number      int           `db:"number"`
+	Title       string        `db:"title"`
+	Body        *string       `db:"body"`
+	State       string        `db:"state"`
+	StateReason *string       `db:"state_reason"`
+	Username    string        `db:"username"`
+	Labels      []string      `db:"labels"`
+	Assignees   []string      `db:"assignees"`
+	Milestone   *string       `db:"milestone"`
+	Comments    int           `db:"comments"`
+	CreatedAt   time.Time     `db:"created_at"`
+	UpdatedAt   time.Time     `db:"updated_at"`
+	ClosedAt    *time.Time    `db:"closed_at"`
+	Data        *github.Issue `db:"data"`
+}
+
+type repository struct {
+	Host     string             `db:"host"`
+	RepoID   int                `db:"repo_id"`
+	Repo     string             `db:"repo"`
+	Owner    string             `db:"owner"`
+	Name     string             `db:"name"`
+	Archived bool               `db:"archived"`
+	Fork     bool               `db:"fork"`
+	Data     *github.Repository `db:"data"`
+}
+
+func newRepository(repo *github.Repository) *repository {
+	return &repository{
+		Host:     repoHost(repo),
+		RepoID:   int(repo.GetID()),
+		Repo:     repo.GetFullName(),
+		Owner:    repo.GetOwner().GetLogin(),
+		Name:     repo.GetName(),
+		Archived: repo.GetArchived(),
+		Fork:     repo.GetFork(),
+		Data:     repo,
+	}
+}
+
+func newPullRequest(repo *github.Repository, pr *github.PullRequest) *pullRequest {
+	var mergedAt *time.Time
+	var mergeCommitSHA *string
+	if pr.MergedAt != nil {
+		mergedAt = &pr.MergedAt.Time
+		// Until the pull request is merged, this is the SHA of the test merge commit
+		mergeCommitSHA = pr.MergeCommitSHA
+	}
+	return &pullRequest{
+		Host:           repoHost(repo),
+		PrID:           int(pr.GetID()),
+		RepoID:         int(repo.GetID()),
+		Repo:           repo.GetFullName(),
+		Number:         pr.GetNumber(),
+		Username:       pr.GetUser().GetLogin(),
+		Title:          pr.GetTitle(),
+		Body:           pr.Body,
+		State:          pr.GetState(),
+		Draft:          pr.GetDraft(),
+		MergedAt:       mergedAt,
+		CreatedAt:      pr.GetCreatedAt().Time,
+		UpdatedAt:      pr.GetUpdatedAt().Time,
+		HeadSHA:        pr.GetHead().GetSHA(),
+		HeadRef:        pr.GetHead().GetRef(),
+		MergeCommitSHA: mergeCommitSHA,
+		Data:           pr,
+	}
+}
+
+func newPullRequestReview(repo *github.Repository, prID int, review *github.PullRequestReview) *pullRequestReview {
+	return &pullRequestReview{
+		Host:        repoHost(repo),
+		ReviewID:    int(review.GetID()),
+		PrID:        prID,
+		Repo:        repo.GetFullName(),
+		Username:    review.GetUser().GetLogin(),
+		State:       review.GetState(),
+		SubmittedAt: review.GetSubmittedAt().Time,
+		CommitID:    review.GetCommitID(),
+		Data:        review,
+	}
+}
+
+func newPullRequestCommit(repo *github.Repository, prID int, commit *github.RepositoryCommit) *pullRequestCommit {
+	return &pullRequestCommit{
+		Host:            repoHost(repo),
+		PrID:            prID,
+		Repo:            repo.GetFullName(),
+		SHA:             commit.GetSHA(),
+		AuthorLogin:     commit.GetAuthor().GetLogin(),
+		AuthorEmail:     commit.GetCommit().GetAuthor().GetEmail(),
+		CommitterLogin:  commit.GetCommitter().GetLogin(),
+		CommitterEmail:  commit.GetCommit().GetCommitter().GetEmail(),
+		AuthoredAt:      commit.GetCommit().GetAuthor().GetDate().Time,
+		CommittedAt:     commit.GetCommit().GetCommitter().GetDate().Time,
+		MessageHeadline: strings.SplitN(commit.GetCommit().GetMessage(), "\n", 2)[0],
+		Data:            commit,
+	}
+}
+
+func newPullRequestReviewComment(repo *github.Repository, prID int, comment *github.PullRequestComment,
+) *pullRequestReviewComment {
+	var reviewID, inReplyTo *int
+	if comment.PullRequestReviewID != nil {
+		id := int(comment.GetPullRequestReviewID())
+		reviewID = &id
+	}
+	if comment.InReplyTo != nil {
+		id := int(comment.GetInReplyTo())
+		inReplyTo = &id
+	}
+	return &pullRequestReviewComment{
+		Host:         repoHost(repo),
+		CommentID:    int(comment.GetID()),
+		PrID:         prID,
+		ReviewID:     reviewID,
+		Repo:         repo.GetFullName(),
+		Username:     comment.GetUser().GetLogin(),
+		Path:         comment.GetPath(),
+		Line:         comment.Line,
+		OriginalLine: comment.OriginalLine,
+		StartLine:    comment.StartLine,
+		Side:         comment.Side,
+		InReplyTo:    inReplyTo,
+		DiffHunk:     comment.GetDiffHunk(),
+		CommitID:     comment.GetCommitID(),
+		Body:         comment.GetBody(),
+		CreatedAt:    comment.GetCreatedAt().Time,
+		UpdatedAt:    comment.GetUpdatedAt().Time,
+		Data:         comment,
+	}
+}
+
+func newPullRequestComment(repo *github.Repository, prID int, comment *github.IssueComment) *pullRequestComment {
+	return &pullRequestComment{
+		Host:      repoHost(repo),
+		CommentID: int(comment.GetID()),
+		PrID:      prID,
+		Repo:      repo.GetFullName(),
+		Username:  comment.GetUser().GetLogin(),
+		Body:      comment.GetBody(),
+		CreatedAt: comment.GetCreatedAt().Time,
+		UpdatedAt: comment.GetUpdatedAt().Time,
+		Data:      comment,
+	}
+}
+
+func newPullRequestFile(repo *github.Repository, prID int, file *github.CommitFile) *pullRequestFile {
+	return &pullRequestFile{
+		Host:             repoHost(repo),
+		PrID:             prID,
+		Repo:             repo.GetFullName(),
+		Filename:         file.GetFilename(),
+		Status:           file.GetStatus(),
+		Additions:        file.GetAdditions(),
+		Deletions:        file.GetDeletions(),
+		Changes:          file.GetChanges(),
+		PreviousFilename: file.PreviousFilename,
+		SHA:              file.SHA,
+	}
+}
+
+func newWorkflowRun(repo *github.Repository, run *github.WorkflowRun) *workflowRun {
+	var runStartedAt *time.Time
+	if run.RunStartedAt != nil {
+		runStartedAt = &run.RunStartedAt.Time
+	}
+	// Only pull requests from the same repository are linked to their runs
+	prIDs := make([]int64, 0, len(run.PullRequests))
+	prNumbers := make([]int, 0, len(run.PullRequests))
+	for _, pr := range run.PullRequests {
+		prIDs = append(prIDs, pr.GetID())
+		prNumbers = append(prNumbers, pr.GetNumber())
+	}
+	return &workflowRun{
+		Host:         repoHost(repo),
+		RunID:        int(run.GetID()),
+		Repo:         repo.GetFullName(),
+		WorkflowID:   int(run.GetWorkflowID()),
+		Name:         run.GetName(),
+		Event:        run.GetEvent(),
+		Status:       run.GetStatus(),
+		Conclusion:   run.Conclusion,
+		HeadBranch:   run.GetHeadBranch(),
+		HeadSHA:      run.GetHeadSHA(),
+		RunNumber:    run.GetRunNumber(),
+		RunAttempt:   run.GetRunAttempt(),
+		Actor:        run.GetActor().GetLogin(),
+		PrIDs:        prIDs,
+		PrNumbers:    prNumbers,
+		CreatedAt:    run.GetCreatedAt().Time,
+		RunStartedAt: runStartedAt,
+		UpdatedAt:    run.GetUpdatedAt().Time,
+		Data:         run,
+	}
+}
+
+func newWorkflowJob(repo *github.Repository, job *github.WorkflowJob) *workflowJob {
+	var createdAt, startedAt, completedAt *time.Time
+	if job.CreatedAt != nil {
+		createdAt = &job.CreatedAt.Time
+	}
+	if job.StartedAt != nil {
+		startedAt = &job.StartedAt.Time
+	}
+	if job.CompletedAt != nil {
+		completedAt = &job.CompletedAt.Time
+	}
+	return &workflowJob{
+		Host:        repoHost(repo),
+		JobID:       int(job.GetID()),
+		RunID:       int(job.GetRunID()),
+		RunAttempt:  int(job.GetRunAttempt()),
+		Repo:        repo.GetFullName(),
+		Name:        job.GetName(),
+		Status:      job.GetStatus(),
+		Conclusion:  job.Conclusion,
+		HeadSHA:     job.GetHeadSHA(),
+		RunnerName:  job.RunnerName,
+		CreatedAt:   createdAt,
+		StartedAt:   startedAt,
+		CompletedAt: completedAt,
+		Data:        job,
+	}
+}
+
+func newPullRequestCheckRun(repo *github.Repository, prID int, checkRun *github.CheckRun) *pullRequestCheckRun {
+	var startedAt, completedAt *time.Time
+	if checkRun.StartedAt != nil {
+		startedAt = &checkRun.StartedAt.Time
+	}
+	if checkRun.CompletedAt != nil {
+		completedAt = &checkRun.CompletedAt.Time
+	}
+	return &pullRequestCheckRun{
+		Host:        repoHost(repo),
+		CheckRunID:  int(checkRun.GetID()),
+		PrID:        prID,
+		Repo:        repo.GetFullName(),
+		HeadSHA:     checkRun.GetHeadSHA(),
+		Name:        checkRun.GetName(),
+		App:         checkRun.GetApp().GetSlug(),
+		Status:      checkRun.GetStatus(),
+		Conclusion:  checkRun.Conclusion,
+		StartedAt:   startedAt,
+		CompletedAt: completedAt,
+		Data:        checkRun,
+	}
+}
+
+func newPullRequestCommitStatus(repo *github.Repository, prID int, sha string, status *github.RepoStatus,
+) *pullRequestCommitStatus {
+	return &pullRequestCommitStatus{
+		Host:        repoHost(repo),
+		StatusID:    int(status.GetID()),
+		PrID:        prID,
+		Repo:        repo.GetFullName(),
+		HeadSHA:     sha,
+		Context:     status.GetContext(),
+		State:       status.GetState(),
+		Description: status.Description,
+		TargetURL:   status.TargetURL,
+		Creator:     status.GetCreator().GetLogin(),
+		CreatedAt:   status.GetCreatedAt().Time,
+		UpdatedAt:   status.GetUpdatedAt().Time,
+		Data:        status,
+	}
+}
+
+// newDeployment derives the state of a deployment from its statuses: the latest status is its state, the first
+// success or failure is when it finished.
+func newDeployment(repo *github.Repository, d *github.Deployment, statuses []*deploymentStatus) *deployment {
+	dep := &deployment{
+		Host:         repoHost(repo),
+		DeploymentID: int(d.GetID()),
+		Repo:         repo.GetFullName(),
+		Environment:  d.GetEnvironment(),
+		SHA:          d.GetSHA(),
+		Ref:          d.GetRef(),
+		Task:         d.GetTask(),
+		Creator:      d.GetCreator().GetLogin(),
+		CreatedAt:    d.GetCreatedAt().Time,
+		UpdatedAt:    d.GetUpdatedAt().Time,
+		Data:         d,
+	}
+	var latest *deploymentStatus
+	for _, status := range statuses {
+		createdAt := status.CreatedAt
+		if latest == nil || createdAt.After(latest.CreatedAt) {
+			latest = status
+		}
+		switch status.State {
+		case deploymentStateSuccess:
+			if dep.SucceededAt == nil || createdAt.Before(*dep.SucceededAt) {
+				dep.SucceededAt = &createdAt
+			}
+		case deploymentStateFailure, deploymentStateError:
+			if dep.FailedAt == nil || createdAt.Before(*dep.FailedAt) {
+				dep.FailedAt = &createdAt
+			}
+		}
+	}
+	if latest != nil {
+		dep.State = &latest.State
+	}
+	return dep
+}
+
+func newDeploymentStatus(repo *github.Repository, deploymentID int, status *github.DeploymentStatus) *deploymentStatus {
+	return &deploymentStatus{
+		Host:         repoHost(repo),
+		StatusID:     int(status.GetID()),
+		DeploymentID: deploymentID,
+		Repo:         repo.GetFullName(),
+		State:        status.GetState(),
+		Description:  status.Description,
+		Creator:      status.GetCreator().GetLogin(),
+		CreatedAt:    status.GetCreatedAt().Time,
+		Data:         status,
+	}
+}
+
+func newRelease(repo *github.Repository, r *github.RepositoryRelease) *release {
+	var publishedAt *time.Time
+	if r.PublishedAt != nil {
+		publishedAt = &r.PublishedAt.Time
+	}
+	return &release{
+		Host:            repoHost(repo),
+		ReleaseID:       int(r.GetID()),
+		Repo:            repo.GetFullName(),
+		TagName:         r.GetTagName(),
+		Name:            r.GetName(),
+		Draft:           r.GetDraft(),
+		Prerelease:      r.GetPrerelease(),
+		TargetCommitish: r.GetTargetCommitish(),
+		Author:          r.GetAuthor().GetLogin(),
+		CreatedAt:       r.GetCreatedAt().Time,
+		PublishedAt:     publishedAt,
+		Data:            r,
+	}
+}
+
+func newIssue(repo *github.Repository, i *github.Issue) *issue {
+	var milestone *string
+	if i.Milestone != nil {
+		milestone = i.Milestone.Title
+	}
+	var closedAt *time.Time
+	if i.ClosedAt != nil {
+		closedAt = &i.ClosedAt.Time
+	}
+	return &issue{
+		Host:        repoHost(repo),
+		IssueID:     int(i.GetID()),
+		Repo:        repo.GetFullName(),
+		Number:      i.GetNumber(),
+		Title:       i.GetTitle(),
+		Body:        i.Body,
+		State:       i.GetState(),
+		StateReason: i.StateReason,
+		Username:    i.GetUser().GetLogin(),
+		Labels:      lo.Map(i.Labels, func(l *github.Label, _ int) string { return l.GetName() }),
+		Assignees:   lo.Map(i.Assignees, func(u *github.User, _ int) string { return u.GetLogin() }),
+		Milestone:   milestone,
+		Comments:    i.GetComments(),
+		CreatedAt:   i.GetCreatedAt().Time,
+		UpdatedAt:   i.GetUpdatedAt().Time,
+		ClosedAt:    closedAt,
+		Data:        i,
+	}
+}
+
+func newPullRequestEvent(repo *github.Repository, prID int, event *github.IssueEvent) *pullRequestEvent {
+	var eventID *int
+	if event.ID != nil { // Events synced using GraphQL have no REST ID
+		id := int(event.GetID())
+		eventID = &id
+	}
+	var requestedReviewer, requestedTeam, label, assignee *string
+	if event.RequestedReviewer != nil {
+		requestedReviewer = event.RequestedReviewer.Login
+	}
+	if event.RequestedTeam != nil {
+		requestedTeam = event.RequestedTeam.Slug
+	}
+	if event.Label != nil {
+		label = event.Label.Name
+	}
+	if event.Assignee != nil {
+		assignee = event.Assignee.Login
+	}
+	return &pullRequestEvent{
+		Host:              repoHost(repo),
+		EventID:           eventID,
+		PrID:              prID,
+		Repo:              repo.GetFullName(),
+		Event:             event.GetEvent(),
+		Actor:             event.GetActor().GetLogin(),
+		CreatedAt:         event.GetCreatedAt().Time,
+		RequestedReviewer: requestedReviewer,
+		RequestedTeam:     requestedTeam,
+		Label:             label,
+		Assignee:          assignee,
+		CommitID:          event.CommitID,
+		Data:              event,
+	}
+}
+
+func lastReadyForReviewAt(events []*pullRequestEvent) *time.Time {
+	var lastReadyForReviewAt *time.Time
+	for _, event := range events {
+		if event.Event != "ready_for_review" {
+			continue
+		}
+		if lastReadyForReviewAt == nil || event.CreatedAt.After(*lastReadyForReviewAt) {
+			createdAt := event.CreatedAt
+			lastReadyForReviewAt = &createdAt
+		}
+	}
+	return lastReadyForReviewAt
+}
+
+func firstCommitAt(current *time.Time, commits []*pullRequestCommit) *time.Time {
+	for _, commit := range commits {
+		if commit.AuthoredAt.IsZero() {
+			continue
+		}
+		if current == nil || commit.AuthoredAt.Before(*current) {
+			authoredAt := commit.AuthoredAt
+			current = &authoredAt
+		}
+	}
+	return current
+}
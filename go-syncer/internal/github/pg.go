@@ -2,66 +2,776 @@ package github
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
 )
 
 func upsertPullRequests(ctx context.Context, db *sqlx.DB, pullRequests []*pullRequest) error {
-	if len(pullRequests) == 0 {
+	return pg.UpsertPullRequests(ctx, db, lo.Map(pullRequests, func(pr *pullRequest, _ int) *pg.PullRequest {
+		return &pg.PullRequest{
+			Host:                 pr.Host,
+			Provider:             provider,
+			PrID:                 int64(pr.PrID),
+			RepoID:               lo.ToPtr(int64(pr.RepoID)),
+			Repo:                 pr.Repo,
+			Number:               pr.Number,
+			Username:             pr.Username,
+			Title:                pr.Title,
+			Body:                 pr.Body,
+			State:                pr.State,
+			Draft:                pr.Draft,
+			Additions:            &pr.Additions,
+			Deletions:            &pr.Deletions,
+			ChangedFiles:         &pr.ChangedFiles,
+			MergedAt:             pr.MergedAt,
+			CreatedAt:            pr.CreatedAt,
+			UpdatedAt:            pr.UpdatedAt,
+			LastReadyForReviewAt: pr.LastReadyForReviewAt,
+			FirstCommitAt:        pr.FirstCommitAt,
+			HeadSHA:              pr.HeadSHA,
+			HeadRef:              pr.HeadRef,
+			CIState:              pr.CIState,
+			CIStartedAt:          pr.CIStartedAt,
+			CICompletedAt:        pr.CICompletedAt,
+			MergeCommitSHA:       pr.MergeCommitSHA,
+			Data:                 pr.Data,
+		}
+	}))
+}
+
+func upsertPullRequestReviews(ctx context.Context, db *sqlx.DB, reviews []*pullRequestReview) error {
+	return pg.UpsertPullRequestReviews(ctx, db, lo.Map(reviews, func(review *pullRequestReview, _ int) *pg.PullRequestReview {
+		return &pg.PullRequestReview{
+			Host:        review.Host,
+			Provider:    provider,
+			ReviewID:    int64(review.ReviewID),
+			PrID:        int64(review.PrID),
+			Repo:        review.Repo,
+			Username:    review.Username,
+			State:       review.State,
+			SubmittedAt: review.SubmittedAt,
+			CommitID:    review.CommitID,
+			Data:        review.Data,
+		}
+	}))
+}
+
+func upsertRepositories(ctx context.Context, db *sqlx.DB, repositories []*repository) error {
+	if len(repositories) == 0 {
 		return nil
 	}
 	if _, err := db.NamedExecContext(ctx, `
-			INSERT INTO pull_requests (
-				pr_id, repo, repo_id, number, username, title, body, state, draft, additions, deletions, changed_files,
-				merged_at, created_at, updated_at, last_ready_for_review_at, data
+			INSERT INTO repositories (
+				host, repo_id, repo, owner, name, archived, fork, first_discovered_at, last_discovered_at, data
+			)
+			VALUES (:host, :repo_id, :repo, :owner, :name, :archived, :fork, NOW(), NOW(), :data)
+			ON CONFLICT (host, repo_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				owner = EXCLUDED.owner,
+				name = EXCLUDED.name,
+				archived = EXCLUDED.archived,
+				fork = EXCLUDED.fork,
+				last_discovered_at = EXCLUDED.last_discovered_at,
+				data = EXCLUDED.data
+			`, repositories,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert repositories")
+	}
+	metrics.AddRowsUpserted("repositories", len(repositories))
+	return nil
+}
+
+// replacePullRequestCommits upserts the commits of a pull request and deletes the ones it no longer has, e.g. after a
+// force-push or a rebase.
+func replacePullRequestCommits(ctx context.Context, db *sqlx.DB, pr *pullRequest, commits []*pullRequestCommit) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	shas := lo.Map(commits, func(c *pullRequestCommit, _ int) string { return c.SHA })
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM pull_request_commits WHERE host = $1 AND pr_id = $2 AND sha <> ALL($3)`, pr.Host, pr.PrID, shas,
+	); err != nil {
+		return errors.Wrap(err, "failed to delete pull request commits")
+	}
+	if len(commits) > 0 {
+		if _, err := tx.NamedExecContext(ctx, `
+			INSERT INTO pull_request_commits (
+				host, pr_id, repo, sha, author_login, author_email, committer_login, committer_email,
+				authored_at, committed_at, message_headline, data
 			)
 			VALUES (
-				:pr_id, :repo, :repo_id, :number, :username, :title, :body, :state, :draft, :additions, :deletions, :changed_files,
-				:merged_at, :created_at, :updated_at, :last_ready_for_review_at, :data
+				:host, :pr_id, :repo, :sha, :author_login, :author_email, :committer_login, :committer_email,
+				:authored_at, :committed_at, :message_headline, :data
 			)
-			ON CONFLICT (pr_id) DO UPDATE
+			ON CONFLICT (host, pr_id, sha) DO UPDATE
 			SET repo = EXCLUDED.repo,
-				repo_id = EXCLUDED.repo_id,
-				number = EXCLUDED.number,
+				author_login = EXCLUDED.author_login,
+				author_email = EXCLUDED.author_email,
+				committer_login = EXCLUDED.committer_login,
+				committer_email = EXCLUDED.committer_email,
+				authored_at = EXCLUDED.authored_at,
+				committed_at = EXCLUDED.committed_at,
+				message_headline = EXCLUDED.message_headline,
+				data = EXCLUDED.data
+			`, commits,
+		); err != nil {
+			return errors.Wrap(err, "failed to insert pull request commits")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit pull request commits")
+	}
+	metrics.AddRowsUpserted("pull_request_commits", len(commits))
+	return nil
+}
+
+func upsertPullRequestReviewComments(ctx context.Context, db *sqlx.DB, comments []*pullRequestReviewComment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_request_review_comments (
+				host, comment_id, pr_id, review_id, repo, username, path, line, original_line, start_line, side,
+				in_reply_to, diff_hunk, commit_id, body, created_at, updated_at, data
+			)
+			VALUES (
+				:host, :comment_id, :pr_id, :review_id, :repo, :username, :path, :line, :original_line, :start_line, :side,
+				:in_reply_to, :diff_hunk, :commit_id, :body, :created_at, :updated_at, :data
+			)
+			ON CONFLICT (host, comment_id) DO UPDATE
+			SET pr_id = EXCLUDED.pr_id,
+				review_id = EXCLUDED.review_id,
+				repo = EXCLUDED.repo,
 				username = EXCLUDED.username,
-				title = EXCLUDED.title,
+				path = EXCLUDED.path,
+				line = EXCLUDED.line,
+				original_line = EXCLUDED.original_line,
+				start_line = EXCLUDED.start_line,
+				side = EXCLUDED.side,
+				in_reply_to = EXCLUDED.in_reply_to,
+				diff_hunk = EXCLUDED.diff_hunk,
+				commit_id = EXCLUDED.commit_id,
 				body = EXCLUDED.body,
-				state = EXCLUDED.state,
-				draft = EXCLUDED.draft,
-				additions = EXCLUDED.additions,
-				deletions = EXCLUDED.deletions,
-				changed_files = EXCLUDED.changed_files,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				data = EXCLUDED.data
+			`, comments,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull request review comments")
+	}
+	metrics.AddRowsUpserted("pull_request_review_comments", len(comments))
+	return nil
+}
+
+func upsertPullRequestComments(ctx context.Context, db *sqlx.DB, comments []*pullRequestComment) error {
+	return pg.UpsertPullRequestComments(ctx, db, lo.Map(comments, func(comment *pullRequestComment, _ int) *pg.PullRequestComment {
+		return &pg.PullRequestComment{
+			Host:      comment.Host,
+			Provider:  provider,
+			CommentID: int64(comment.CommentID),
+			PrID:      int64(comment.PrID),
+			Repo:      comment.Repo,
+			Username:  comment.Username,
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt,
+			UpdatedAt: comment.UpdatedAt,
+			Data:      comment.Data,
+		}
+	}))
+}
+
+// deletePullRequestCommentsExcept deletes the comments of a pull request that aren't in commentIDs, i.e. the comments
+// that were deleted since they were synced. table is pull_request_comments or pull_request_review_comments.
+func deletePullRequestCommentsExcept(ctx context.Context, db *sqlx.DB, table string, pr *pullRequest, commentIDs []int,
+) error {
+	if _, err := db.ExecContext(ctx,
+		`DELETE FROM `+table+` WHERE host = $1 AND pr_id = $2 AND comment_id <> ALL($3)`, pr.Host, pr.PrID, commentIDs,
+	); err != nil {
+		return errors.Wrapf(err, "failed to delete deleted comments from %s", table)
+	}
+	return nil
+}
+
+// deleteComment deletes a comment that was deleted on GitHub. table is pull_request_comments or
+// pull_request_review_comments.
+func deleteComment(ctx context.Context, db *sqlx.DB, table, host string, commentID int64) error {
+	if _, err := db.ExecContext(ctx,
+		`DELETE FROM `+table+` WHERE host = $1 AND comment_id = $2`, host, commentID,
+	); err != nil {
+		return errors.Wrapf(err, "failed to delete comment from %s", table)
+	}
+	return nil
+}
+
+func upsertPullRequestCycleTimes(ctx context.Context, db *sqlx.DB, cycleTimes []*pullRequestCycleTime) error {
+	if len(cycleTimes) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_request_cycle_times (
+				host, pr_id, repo, number, username, first_commit_at, ready_for_review_at, first_review_at, approved_at,
+				merged_at, coding_time_seconds, pickup_time_seconds, review_time_seconds, merge_time_seconds,
+				cycle_time_seconds, ci_time_seconds, computed_at
+			)
+			VALUES (
+				:host, :pr_id, :repo, :number, :username, :first_commit_at, :ready_for_review_at, :first_review_at, :approved_at,
+				:merged_at, :coding_time_seconds, :pickup_time_seconds, :review_time_seconds, :merge_time_seconds,
+				:cycle_time_seconds, :ci_time_seconds, NOW()
+			)
+			ON CONFLICT (host, pr_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				number = EXCLUDED.number,
+				username = EXCLUDED.username,
+				first_commit_at = EXCLUDED.first_commit_at,
+				ready_for_review_at = EXCLUDED.ready_for_review_at,
+				first_review_at = EXCLUDED.first_review_at,
+				approved_at = EXCLUDED.approved_at,
 				merged_at = EXCLUDED.merged_at,
+				coding_time_seconds = EXCLUDED.coding_time_seconds,
+				pickup_time_seconds = EXCLUDED.pickup_time_seconds,
+				review_time_seconds = EXCLUDED.review_time_seconds,
+				merge_time_seconds = EXCLUDED.merge_time_seconds,
+				cycle_time_seconds = EXCLUDED.cycle_time_seconds,
+				ci_time_seconds = EXCLUDED.ci_time_seconds,
+				computed_at = EXCLUDED.computed_at
+			`, cycleTimes,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull request cycle times")
+	}
+	metrics.AddRowsUpserted("pull_request_cycle_times", len(cycleTimes))
+	return nil
+}
+
+// getWorkflowRunsUpdatedAt returns the update time of the synced runs among runIDs.
+func getWorkflowRunsUpdatedAt(ctx context.Context, db *sqlx.DB, host string, runIDs []int64) (map[int64]time.Time, error) {
+	rows := []struct {
+		RunID     int64     `db:"run_id"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}{}
+	if err := db.SelectContext(ctx, &rows,
+		`SELECT run_id, updated_at FROM workflow_runs WHERE host = $1 AND run_id = ANY($2)`, host, runIDs,
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to get synced workflow runs")
+	}
+	updatedAt := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		updatedAt[row.RunID] = row.UpdatedAt
+	}
+	return updatedAt, nil
+}
+
+func upsertWorkflowRuns(ctx context.Context, db *sqlx.DB, runs []*workflowRun) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO workflow_runs (
+				host, run_id, repo, workflow_id, name, event, status, conclusion, head_branch, head_sha, run_number,
+				run_attempt, actor, pr_ids, pr_numbers, created_at, run_started_at, updated_at, data
+			)
+			VALUES (
+				:host, :run_id, :repo, :workflow_id, :name, :event, :status, :conclusion, :head_branch, :head_sha, :run_number,
+				:run_attempt, :actor, :pr_ids, :pr_numbers, :created_at, :run_started_at, :updated_at, :data
+			)
+			ON CONFLICT (host, run_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				workflow_id = EXCLUDED.workflow_id,
+				name = EXCLUDED.name,
+				event = EXCLUDED.event,
+				status = EXCLUDED.status,
+				conclusion = EXCLUDED.conclusion,
+				head_branch = EXCLUDED.head_branch,
+				head_sha = EXCLUDED.head_sha,
+				run_number = EXCLUDED.run_number,
+				run_attempt = EXCLUDED.run_attempt,
+				actor = EXCLUDED.actor,
+				pr_ids = EXCLUDED.pr_ids,
+				pr_numbers = EXCLUDED.pr_numbers,
 				created_at = EXCLUDED.created_at,
+				run_started_at = EXCLUDED.run_started_at,
 				updated_at = EXCLUDED.updated_at,
-				last_ready_for_review_at = EXCLUDED.last_ready_for_review_at,
 				data = EXCLUDED.data
-			`, pullRequests,
+			`, runs,
 	); err != nil {
-		return errors.Wrap(err, "failed to insert pull request")
+		return errors.Wrap(err, "failed to insert workflow runs")
 	}
+	metrics.AddRowsUpserted("workflow_runs", len(runs))
 	return nil
 }
 
-func upsertPullRequestReviews(ctx context.Context, db *sqlx.DB, reviews []*pullRequestReview) error {
-	if len(reviews) == 0 {
+func upsertWorkflowJobs(ctx context.Context, db *sqlx.DB, jobs []*workflowJob) error {
+	if len(jobs) == 0 {
 		return nil
 	}
 	if _, err := db.NamedExecContext(ctx, `
-			INSERT INTO pull_request_reviews (review_id, pr_id, repo, username, state, submitted_at, commit_id, data)
-			VALUES (:review_id, :pr_id, :repo, :username, :state, :submitted_at, :commit_id, :data)
-			ON CONFLICT (review_id) DO UPDATE
-			SET pr_id = EXCLUDED.pr_id,
+			INSERT INTO workflow_jobs (
+				host, job_id, run_id, run_attempt, repo, name, status, conclusion, head_sha, runner_name, created_at,
+				started_at, completed_at, data
+			)
+			VALUES (
+				:host, :job_id, :run_id, :run_attempt, :repo, :name, :status, :conclusion, :head_sha, :runner_name, :created_at,
+				:started_at, :completed_at, :data
+			)
+			ON CONFLICT (host, job_id) DO UPDATE
+			SET run_id = EXCLUDED.run_id,
+				run_attempt = EXCLUDED.run_attempt,
 				repo = EXCLUDED.repo,
-				username = EXCLUDED.username,
+				name = EXCLUDED.name,
+				status = EXCLUDED.status,
+				conclusion = EXCLUDED.conclusion,
+				head_sha = EXCLUDED.head_sha,
+				runner_name = EXCLUDED.runner_name,
+				created_at = EXCLUDED.created_at,
+				started_at = EXCLUDED.started_at,
+				completed_at = EXCLUDED.completed_at,
+				data = EXCLUDED.data
+			`, jobs,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert workflow jobs")
+	}
+	metrics.AddRowsUpserted("workflow_jobs", len(jobs))
+	return nil
+}
+
+func upsertPullRequestCheckRuns(ctx context.Context, db *sqlx.DB, checkRuns []*pullRequestCheckRun) error {
+	if len(checkRuns) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_request_check_runs (
+				host, check_run_id, pr_id, repo, head_sha, name, app, status, conclusion, started_at, completed_at, data
+			)
+			VALUES (
+				:host, :check_run_id, :pr_id, :repo, :head_sha, :name, :app, :status, :conclusion, :started_at, :completed_at, :data
+			)
+			ON CONFLICT (host, pr_id, check_run_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				head_sha = EXCLUDED.head_sha,
+				name = EXCLUDED.name,
+				app = EXCLUDED.app,
+				status = EXCLUDED.status,
+				conclusion = EXCLUDED.conclusion,
+				started_at = EXCLUDED.started_at,
+				completed_at = EXCLUDED.completed_at,
+				data = EXCLUDED.data
+			`, checkRuns,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull request check runs")
+	}
+	metrics.AddRowsUpserted("pull_request_check_runs", len(checkRuns))
+	return nil
+}
+
+func upsertPullRequestCommitStatuses(ctx context.Context, db *sqlx.DB, statuses []*pullRequestCommitStatus) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_request_commit_statuses (
+				host, status_id, pr_id, repo, head_sha, context, state, description, target_url, creator, created_at,
+				updated_at, data
+			)
+			VALUES (
+				:host, :status_id, :pr_id, :repo, :head_sha, :context, :state, :description, :target_url, :creator, :created_at,
+				:updated_at, :data
+			)
+			ON CONFLICT (host, pr_id, status_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				head_sha = EXCLUDED.head_sha,
+				context = EXCLUDED.context,
 				state = EXCLUDED.state,
-				submitted_at = EXCLUDED.submitted_at,
-				commit_id = EXCLUDED.commit_id,
+				description = EXCLUDED.description,
+				target_url = EXCLUDED.target_url,
+				creator = EXCLUDED.creator,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				data = EXCLUDED.data
+			`, statuses,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull request commit statuses")
+	}
+	metrics.AddRowsUpserted("pull_request_commit_statuses", len(statuses))
+	return nil
+}
+
+func upsertDeployments(ctx context.Context, db *sqlx.DB, deployments []*deployment) error {
+	if len(deployments) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO deployments (
+				host, deployment_id, repo, environment, sha, ref, task, creator, state, created_at, updated_at,
+				succeeded_at, failed_at, data
+			)
+			VALUES (
+				:host, :deployment_id, :repo, :environment, :sha, :ref, :task, :creator, :state, :created_at, :updated_at,
+				:succeeded_at, :failed_at, :data
+			)
+			ON CONFLICT (host, deployment_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				environment = EXCLUDED.environment,
+				sha = EXCLUDED.sha,
+				ref = EXCLUDED.ref,
+				task = EXCLUDED.task,
+				creator = EXCLUDED.creator,
+				state = EXCLUDED.state,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				succeeded_at = EXCLUDED.succeeded_at,
+				failed_at = EXCLUDED.failed_at,
+				data = EXCLUDED.data
+			`, deployments,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert deployments")
+	}
+	metrics.AddRowsUpserted("deployments", len(deployments))
+	return nil
+}
+
+func upsertDeploymentStatuses(ctx context.Context, db *sqlx.DB, statuses []*deploymentStatus) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO deployment_statuses (host, status_id, deployment_id, repo, state, description, creator, created_at, data)
+			VALUES (:host, :status_id, :deployment_id, :repo, :state, :description, :creator, :created_at, :data)
+			ON CONFLICT (host, status_id) DO UPDATE
+			SET deployment_id = EXCLUDED.deployment_id,
+				repo = EXCLUDED.repo,
+				state = EXCLUDED.state,
+				description = EXCLUDED.description,
+				creator = EXCLUDED.creator,
+				created_at = EXCLUDED.created_at,
+				data = EXCLUDED.data
+			`, statuses,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert deployment statuses")
+	}
+	metrics.AddRowsUpserted("deployment_statuses", len(statuses))
+	return nil
+}
+
+func insertDeploymentCommits(ctx context.Context, db *sqlx.DB, commits []*deploymentCommit) error {
+	if len(commits) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO deployment_commits (host, deployment_id, repo, sha)
+			VALUES (:host, :deployment_id, :repo, :sha)
+			ON CONFLICT (host, deployment_id, sha) DO NOTHING
+			`, commits,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert deployment commits")
+	}
+	metrics.AddRowsUpserted("deployment_commits", len(commits))
+	return nil
+}
+
+// getPreviousDeploymentSHA returns the SHA of the last successful deployment to the same environment before the given
+// one, or nil if it's the first.
+func getPreviousDeploymentSHA(ctx context.Context, db *sqlx.DB, dep *deployment) (*string, error) {
+	var sha *string
+	err := db.GetContext(ctx, &sha, `
+		SELECT sha FROM deployments
+		WHERE host = $1 AND repo = $2 AND environment = $3 AND succeeded_at IS NOT NULL AND created_at < $4
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, dep.Host, dep.Repo, dep.Environment, dep.CreatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, "failed to get previous deployment")
+	}
+	return sha, nil
+}
+
+func upsertReleases(ctx context.Context, db *sqlx.DB, releases []*release) error {
+	if len(releases) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO releases (
+				host, release_id, repo, tag_name, name, draft, prerelease, target_commitish, author, created_at, published_at, data
+			)
+			VALUES (
+				:host, :release_id, :repo, :tag_name, :name, :draft, :prerelease, :target_commitish, :author, :created_at,
+				:published_at, :data
+			)
+			ON CONFLICT (host, release_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				tag_name = EXCLUDED.tag_name,
+				name = EXCLUDED.name,
+				draft = EXCLUDED.draft,
+				prerelease = EXCLUDED.prerelease,
+				target_commitish = EXCLUDED.target_commitish,
+				author = EXCLUDED.author,
+				created_at = EXCLUDED.created_at,
+				published_at = EXCLUDED.published_at,
+				data = EXCLUDED.data
+			`, releases,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert releases")
+	}
+	metrics.AddRowsUpserted("releases", len(releases))
+	return nil
+}
+
+func getTagSHAs(ctx context.Context, db *sqlx.DB, host, repo string) (map[string]string, error) {
+	tags := []*tag{}
+	if err := db.SelectContext(ctx, &tags, `
+		SELECT host, repo, name, sha, tagged_at FROM tags WHERE host = $1 AND repo = $2
+	`, host, repo); err != nil {
+		return nil, errors.Wrap(err, "failed to get tags")
+	}
+	shas := make(map[string]string, len(tags))
+	for _, t := range tags {
+		shas[t.Name] = t.SHA
+	}
+	return shas, nil
+}
+
+// getPreviousTagSHA returns the SHA of the latest tag before the given one, or nil if it's the first.
+// getPreviousTagSHAs returns the commits of the latest tags before a tag, latest first.
+func getPreviousTagSHAs(ctx context.Context, db *sqlx.DB, t *tag, limit int) ([]string, error) {
+	shas := []string{}
+	if err := db.SelectContext(ctx, &shas, `
+		SELECT sha FROM tags
+		WHERE host = $1 AND repo = $2 AND name != $3 AND tagged_at < $4
+		ORDER BY tagged_at DESC
+		LIMIT $5
+	`, t.Host, t.Repo, t.Name, t.TaggedAt, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to get previous tags")
+	}
+	return shas, nil
+}
+
+// replaceTag stores a tag along with the commits it added, replacing them if the tag was moved.
+func replaceTag(ctx context.Context, db *sqlx.DB, t *tag, commits []*tagCommit) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.NamedExecContext(ctx, `
+		INSERT INTO tags (host, repo, name, sha, tagged_at)
+		VALUES (:host, :repo, :name, :sha, :tagged_at)
+		ON CONFLICT (host, repo, name) DO UPDATE
+		SET sha = EXCLUDED.sha,
+			tagged_at = EXCLUDED.tagged_at
+	`, t); err != nil {
+		return errors.Wrap(err, "failed to insert tag")
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tag_commits WHERE host = $1 AND repo = $2 AND tag = $3`,
+		t.Host, t.Repo, t.Name,
+	); err != nil {
+		return errors.Wrap(err, "failed to delete tag commits")
+	}
+	if len(commits) > 0 {
+		if _, err := tx.NamedExecContext(ctx, `
+			INSERT INTO tag_commits (host, repo, tag, sha)
+			VALUES (:host, :repo, :tag, :sha)
+			ON CONFLICT (host, repo, tag, sha) DO NOTHING
+			`, commits,
+		); err != nil {
+			return errors.Wrap(err, "failed to insert tag commits")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit tag")
+	}
+	metrics.AddRowsUpserted("tags", 1)
+	metrics.AddRowsUpserted("tag_commits", len(commits))
+	return nil
+}
+
+// attributePullRequestReleases sets the first release each merged pull request of a repository shipped in.
+// Tags without a release are released when their commit was made.
+func attributePullRequestReleases(ctx context.Context, db *sqlx.DB, host, repo string) error {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE pull_requests pr
+		SET release_tag = r.tag, released_at = r.released_at
+		FROM (
+			SELECT DISTINCT ON (tc.sha) tc.sha, t.name AS tag, COALESCE(rel.published_at, t.tagged_at) AS released_at
+			FROM tag_commits tc
+			JOIN tags t ON t.host = tc.host AND t.repo = tc.repo AND t.name = tc.tag
+			LEFT JOIN releases rel ON rel.host = t.host AND rel.repo = t.repo AND rel.tag_name = t.name AND NOT rel.draft
+			WHERE tc.host = $1 AND tc.repo = $2
+			ORDER BY tc.sha, COALESCE(rel.published_at, t.tagged_at)
+		) r
+		WHERE pr.host = $1 AND pr.repo = $2 AND pr.merge_commit_sha = r.sha
+			AND (pr.release_tag IS DISTINCT FROM r.tag OR pr.released_at IS DISTINCT FROM r.released_at)
+	`, host, repo); err != nil {
+		return errors.Wrap(err, "failed to attribute pull requests to releases")
+	}
+	return nil
+}
+
+func upsertIssues(ctx context.Context, db *sqlx.DB, issues []*issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO issues (
+				host, issue_id, repo, number, title, body, state, state_reason, username, labels, assignees, milestone, comments,
+				created_at, updated_at, closed_at, data
+			)
+			VALUES (
+				:host, :issue_id, :repo, :number, :title, :body, :state, :state_reason, :username, :labels, :assignees, :milestone,
+				:comments, :created_at, :updated_at, :closed_at, :data
+			)
+			ON CONFLICT (host, issue_id) DO UPDATE
+			SET repo = EXCLUDED.repo,
+				number = EXCLUDED.number,
+				title = EXCLUDED.title,
+				body = EXCLUDED.body,
+				state = EXCLUDED.state,
+				state_reason = EXCLUDED.state_reason,
+				username = EXCLUDED.username,
+				labels = EXCLUDED.labels,
+				assignees = EXCLUDED.assignees,
+				milestone = EXCLUDED.milestone,
+				comments = EXCLUDED.comments,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				closed_at = EXCLUDED.closed_at,
 				data = EXCLUDED.data
-			`, reviews,
+			`, issues,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert issues")
+	}
+	metrics.AddRowsUpserted("issues", len(issues))
+	return nil
+}
+
+// linkPullRequestIssues replaces the issues the pull requests of a repository close, parsed from the closing keywords
+// in their stored bodies: "closes #1", "fixes owner/repo#2" or "resolves https://github.com/owner/repo/issues/3".
+// Links are parsed from the database, so the first run links the pull requests synced before issues were enabled as
+// well. Later runs only parse the pull requests synced since the previous run.
+func linkPullRequestIssues(ctx context.Context, db *sqlx.DB, host, repo string) error {
+	syncedSince, err := pg.GetLastSyncAt(ctx, db, host, repo, pg.SyncEntityIssueLinks)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if syncedSince != nil {
+		// Pull requests upserted while the previous run was parsing may not have been visible to it
+		syncedSince = lo.ToPtr(syncedSince.Add(-issueLinkOverlap))
+	}
+
+	// The run is recorded in the same transaction, so its NOW() is when the pull requests were read
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM pull_request_issues l
+		USING pull_requests pr
+		WHERE l.host = $1 AND l.repo = $2 AND pr.host = l.host AND pr.pr_id = l.pr_id
+			AND ($3::TIMESTAMP IS NULL OR pr.synced_at >= $3::TIMESTAMP)
+	`, host, repo, syncedSince); err != nil {
+		return errors.Wrap(err, "failed to delete pull request issues")
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pull_request_issues (host, pr_id, repo, issue_repo, issue_number, keyword)
+		SELECT pr.host, pr.pr_id, pr.repo, COALESCE(m[2], m[3], pr.repo), m[4]::INT, LOWER(m[1])
+		FROM pull_requests pr,
+			REGEXP_MATCHES(
+				pr.body,
+				'\m(close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+(?:([\w.-]+/[\w.-]+)?#|https?://[^/\s]+/([\w.-]+/[\w.-]+)/issues/)(\d+)\M',
+				'gi'
+			) AS m
+		WHERE pr.host = $1 AND pr.repo = $2 AND ($3::TIMESTAMP IS NULL OR pr.synced_at >= $3::TIMESTAMP)
+		ON CONFLICT (host, pr_id, issue_repo, issue_number) DO NOTHING
+	`, host, repo, syncedSince); err != nil {
+		return errors.Wrap(err, "failed to insert pull request issues")
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO sync_status (host, repo, entity, last_synced)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (host, repo, entity) DO UPDATE
+		SET last_synced = EXCLUDED.last_synced
+	`, host, repo, pg.SyncEntityIssueLinks); err != nil {
+		return errors.Wrap(err, "failed to update last linked time")
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit pull request issues")
+}
+
+// replacePullRequestEvents replaces the stored timeline of a pull request, events have no stable ID across the REST
+// and GraphQL APIs to upsert on.
+func replacePullRequestEvents(ctx context.Context, db *sqlx.DB, pr *pullRequest, events []*pullRequestEvent) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pull_request_events WHERE host = $1 AND pr_id = $2`,
+		pr.Host, pr.PrID,
 	); err != nil {
-		return errors.Wrap(err, "failed to insert pull request review")
+		return errors.Wrap(err, "failed to delete pull request events")
+	}
+	if len(events) > 0 {
+		if _, err := tx.NamedExecContext(ctx, `
+			INSERT INTO pull_request_events (
+				host, event_id, pr_id, repo, event, actor, created_at, requested_reviewer, requested_team, label, assignee,
+				commit_id, data
+			)
+			VALUES (
+				:host, :event_id, :pr_id, :repo, :event, :actor, :created_at, :requested_reviewer, :requested_team, :label, :assignee,
+				:commit_id, :data
+			)
+			`, events,
+		); err != nil {
+			return errors.Wrap(err, "failed to insert pull request events")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit pull request events")
+	}
+	metrics.AddRowsUpserted("pull_request_events", len(events))
+	return nil
+}
+
+// replacePullRequestFiles replaces the stored files of a pull request, files reverted by later commits are no
+// longer part of it.
+func replacePullRequestFiles(ctx context.Context, db *sqlx.DB, pr *pullRequest, files []*pullRequestFile) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pull_request_files WHERE host = $1 AND pr_id = $2`,
+		pr.Host, pr.PrID,
+	); err != nil {
+		return errors.Wrap(err, "failed to delete pull request files")
+	}
+	if len(files) > 0 {
+		if _, err := tx.NamedExecContext(ctx, `
+			INSERT INTO pull_request_files (
+				host, pr_id, repo, filename, status, additions, deletions, changes, previous_filename, sha
+			)
+			VALUES (
+				:host, :pr_id, :repo, :filename, :status, :additions, :deletions, :changes, :previous_filename, :sha
+			)
+			`, files,
+		); err != nil {
+			return errors.Wrap(err, "failed to insert pull request files")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit pull request files")
 	}
+	metrics.AddRowsUpserted("pull_request_files", len(files))
 	return nil
 }
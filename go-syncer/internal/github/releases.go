@@ -0,0 +1,235 @@
+package github
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+const (
+	releasesPerPage = 100
+	tagsPerPage     = 100
+	// previousTagCandidates is how many earlier tags are compared to a new tag to find the one it descends from
+	previousTagCandidates = 5
+)
+
+// graphQLTagCommit is the commit a tag points to, directly or through an annotated tag.
+type graphQLTagCommit struct {
+	Oid           string
+	CommittedDate githubv4.DateTime
+}
+
+type graphQLTag struct {
+	Name   string
+	Target struct {
+		Commit graphQLTagCommit `graphql:"... on Commit"`
+		Tag    struct {
+			Target struct {
+				Commit graphQLTagCommit `graphql:"... on Commit"`
+			}
+		} `graphql:"... on Tag"`
+	}
+}
+
+func (t *graphQLTag) commit() graphQLTagCommit {
+	if t.Target.Commit.Oid != "" {
+		return t.Target.Commit
+	}
+	return t.Target.Tag.Target.Commit
+}
+
+// syncRepoReleases syncs the releases and tags of a repository. Each new tag is compared to the tag before it to find
+// the commits it added, which attributes merged pull requests to the first release that shipped them.
+func syncRepoReleases(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing releases")
+
+	if err := syncReleases(ctx, db, tokenManager, repo); err != nil {
+		return err
+	}
+	if err := syncTags(ctx, db, tokenManager, repo); err != nil {
+		return err
+	}
+	if err := attributePullRequestReleases(ctx, db, repoHost(repo), repo.GetFullName()); err != nil {
+		return err
+	}
+
+	log.Info("Synced releases")
+	return nil
+}
+
+func syncReleases(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository) error {
+	syncEarliestTime := time.Now().UTC().Add(-syncBackTime)
+	opt := &github.ListOptions{PerPage: releasesPerPage}
+	for {
+		releases, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.RepositoryRelease, *github.Response, error) {
+				return client.Repositories.ListReleases(ctx, *repo.Owner.Login, *repo.Name, opt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list releases")
+		}
+		releasesToSync := lo.Filter(releases, func(r *github.RepositoryRelease, _ int) bool {
+			return !r.GetCreatedAt().Before(syncEarliestTime)
+		})
+		if err := upsertReleases(ctx, db, lo.Map(releasesToSync, func(r *github.RepositoryRelease, _ int) *release {
+			return newRelease(repo, r)
+		})); err != nil {
+			return errors.Wrap(err, "failed to insert releases")
+		}
+		if len(releasesToSync) < len(releases) || resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return nil
+}
+
+// syncTags syncs the tags of the sync window, and the last tag before it for the first tag in the window to be compared
+// to. Tags are listed with GraphQL, which orders them by the date of their commit.
+func syncTags(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository) error {
+	log := logging.MustFromContext(ctx)
+
+	existing, err := getTagSHAs(ctx, db, repoHost(repo), repo.GetFullName())
+	if err != nil {
+		return err
+	}
+
+	syncEarliestTime := time.Now().UTC().Add(-syncBackTime)
+	newTags := []*tag{}
+	variables := map[string]interface{}{
+		"owner":       githubv4.String(repo.GetOwner().GetLogin()),
+		"name":        githubv4.String(repo.GetName()),
+		"tagsPerPage": githubv4.Int(tagsPerPage),
+		"cursor":      (*githubv4.String)(nil),
+	}
+	for reachedWindowStart := false; !reachedWindowStart; {
+		var query struct {
+			Repository struct {
+				Refs struct {
+					Nodes    []graphQLTag
+					PageInfo graphQLPageInfo
+				} `graphql:"refs(refPrefix: \"refs/tags/\", first: $tagsPerPage, after: $cursor, orderBy: {field: TAG_COMMIT_DATE, direction: DESC})"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+		}
+		if err := queryGraphQL(ctx, tokenManager, &query, variables); err != nil {
+			return errors.Wrap(err, "failed to list tags")
+		}
+		for i := range query.Repository.Refs.Nodes {
+			t := &query.Repository.Refs.Nodes[i]
+			commit := t.commit()
+			if commit.Oid == "" {
+				continue // Tags of trees or blobs
+			}
+			if existing[t.Name] != commit.Oid {
+				newTags = append(newTags, &tag{
+					Host:     repoHost(repo),
+					Repo:     repo.GetFullName(),
+					Name:     t.Name,
+					SHA:      commit.Oid,
+					TaggedAt: commit.CommittedDate.UTC(),
+				})
+			}
+			if commit.CommittedDate.Before(syncEarliestTime) {
+				reachedWindowStart = true
+				break
+			}
+		}
+		pageInfo := query.Repository.Refs.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+	}
+
+	// Oldest first, so the previous tag is stored before the next one is compared to it
+	sort.Slice(newTags, func(i, j int) bool { return newTags[i].TaggedAt.Before(newTags[j].TaggedAt) })
+	for _, t := range newTags {
+		commits := []*tagCommit{}
+		if !t.TaggedAt.Before(syncEarliestTime) {
+			if commits, err = listTagCommits(ctx, db, tokenManager, repo, t); err != nil {
+				return errors.Wrapf(err, "failed to list commits of tag %s", t.Name)
+			}
+		}
+		if err := replaceTag(ctx, db, t, commits); err != nil {
+			return errors.Wrapf(err, "failed to insert tag %s", t.Name)
+		}
+	}
+	log.Info("Synced tags", "new_tags", len(newTags))
+	return nil
+}
+
+// listTagCommits lists the commits a tag added since the latest earlier tag it descends from, so tags of maintenance
+// branches are compared to the previous tag of their branch. A tag that descends from none of the latest earlier tags,
+// e.g. the first tag of a branch, is compared to the latest one.
+func listTagCommits(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, t *tag,
+) ([]*tagCommit, error) {
+	previousSHAs, err := getPreviousTagSHAs(ctx, db, t, previousTagCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first tag has nothing to compare to
+	if len(previousSHAs) == 0 {
+		return []*tagCommit{{Host: t.Host, Repo: t.Repo, Tag: t.Name, SHA: t.SHA}}, nil
+	}
+
+	var fallback []*tagCommit
+	for i, previousSHA := range previousSHAs {
+		if previousSHA == t.SHA {
+			return []*tagCommit{}, nil
+		}
+		commits, descends, err := compareTag(ctx, tokenManager, repo, previousSHA, t)
+		if err != nil {
+			return nil, err
+		}
+		if descends {
+			return commits, nil
+		}
+		if i == 0 {
+			fallback = commits
+		}
+	}
+	return fallback, nil
+}
+
+// compareTag lists the commits of a tag that aren't in a previous tag, and returns whether the tag descends from it.
+func compareTag(ctx context.Context, tokenManager *TokenManager, repo *github.Repository, previousSHA string, t *tag,
+) ([]*tagCommit, bool, error) {
+	tagCommits := []*tagCommit{}
+	status := ""
+	opt := &github.ListOptions{PerPage: compareCommitsPerPage}
+	for {
+		commits, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.RepositoryCommit, *github.Response, error) {
+				comparison, resp, err := client.Repositories.CompareCommits(ctx, *repo.Owner.Login, *repo.Name, previousSHA, t.SHA, opt)
+				if err != nil {
+					return nil, resp, err
+				}
+				status = comparison.GetStatus()
+				return comparison.Commits, resp, nil
+			},
+		)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to compare commits")
+		}
+		for _, commit := range commits {
+			tagCommits = append(tagCommits, &tagCommit{Host: t.Host, Repo: t.Repo, Tag: t.Name, SHA: commit.GetSHA()})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return tagCommits, status == "ahead" || status == "identical", nil
+}
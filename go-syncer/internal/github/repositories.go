@@ -0,0 +1,163 @@
+package github
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+const orgReposPerPage = 100
+
+// repositorySelector decides which repositories are synced. Repositories listed explicitly are always synced,
+// repositories discovered in an organization are matched against the include/exclude patterns and filters.
+type repositorySelector struct {
+	repos        []config.GitHubRepository
+	orgs         []string
+	include      []string
+	exclude      []string
+	skipArchived bool
+	skipForks    bool
+}
+
+func newRepositorySelector(source *config.GitHubSource) *repositorySelector {
+	return &repositorySelector{
+		repos:        source.Repositories,
+		orgs:         source.Organizations,
+		include:      source.RepositoriesInclude,
+		exclude:      source.RepositoriesExclude,
+		skipArchived: source.SkipArchived,
+		skipForks:    source.SkipForks,
+	}
+}
+
+func (s *repositorySelector) Selected(repo *github.Repository) bool {
+	if lo.ContainsBy(s.repos, func(r config.GitHubRepository) bool {
+		return strings.EqualFold(string(r), repo.GetFullName())
+	}) {
+		return true
+	}
+	if !lo.ContainsBy(s.orgs, func(org string) bool {
+		return strings.EqualFold(org, repo.GetOwner().GetLogin())
+	}) {
+		return false
+	}
+	return s.matchesFilters(repo)
+}
+
+func (s *repositorySelector) matchesFilters(repo *github.Repository) bool {
+	if s.skipArchived && repo.GetArchived() {
+		return false
+	}
+	if s.skipForks && repo.GetFork() {
+		return false
+	}
+	fullName := strings.ToLower(repo.GetFullName())
+	matches := func(pattern string) bool {
+		matched, _ := path.Match(strings.ToLower(pattern), fullName)
+		return matched
+	}
+	if len(s.include) > 0 && !lo.ContainsBy(s.include, matches) {
+		return false
+	}
+	return !lo.ContainsBy(s.exclude, matches)
+}
+
+// discoverRepositories returns the selected repositories. A repository or organization that fails to be fetched is
+// logged and skipped, the repositories that were discovered are returned along with an error listing the failures.
+func discoverRepositories(ctx context.Context, db *sqlx.DB, credentials *Credentials,
+	selector *repositorySelector,
+) ([]*github.Repository, error) {
+	log := logging.MustFromContext(ctx)
+	log.Info("Discovering repositories")
+
+	repos := []*github.Repository{}
+	failures := []string{}
+	for _, repoIdentifier := range selector.repos {
+		repo, err := getRepository(ctx, credentials, repoIdentifier)
+		if err != nil {
+			log.Error(err, "Failed to get repository", "repo", repoIdentifier)
+			failures = append(failures, errors.Wrapf(err, "failed to get repository %s", repoIdentifier).Error())
+			continue
+		}
+		repos = append(repos, repo)
+	}
+
+	for _, org := range selector.orgs {
+		orgRepos, err := listOrgRepositories(ctx, credentials, org)
+		if err != nil {
+			log.Error(err, "Failed to list organization repositories", "org", org)
+			failures = append(failures, errors.Wrapf(err, "failed to list repositories of organization %s", org).Error())
+			continue
+		}
+		selected := lo.Filter(orgRepos, func(repo *github.Repository, _ int) bool {
+			return selector.matchesFilters(repo)
+		})
+		log.Info("Discovered organization repositories", "org", org, "repos", len(orgRepos), "selected", len(selected))
+		repos = append(repos, selected...)
+	}
+
+	repos = lo.UniqBy(repos, func(repo *github.Repository) string {
+		return strings.ToLower(repo.GetFullName())
+	})
+
+	if err := upsertRepositories(ctx, db, lo.Map(repos, func(repo *github.Repository, _ int) *repository {
+		return newRepository(repo)
+	})); err != nil {
+		return nil, errors.Wrap(err, "failed to insert repositories")
+	}
+
+	log.Info("Discovered repositories", "repos", len(repos), "failures", len(failures))
+	if len(failures) > 0 {
+		return repos, errors.New(strings.Join(failures, "; "))
+	}
+	return repos, nil
+}
+
+func getRepository(ctx context.Context, credentials *Credentials, repoIdentifier config.GitHubRepository,
+) (*github.Repository, error) {
+	tokenManager, err := credentials.ForOwner(ctx, repoIdentifier.Owner())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get credentials")
+	}
+	repo, _, err := getEntity(ctx, tokenManager,
+		func(ctx context.Context, client *github.Client) (*github.Repository, *github.Response, error) {
+			return client.Repositories.Get(ctx, repoIdentifier.Owner(), repoIdentifier.Name())
+		},
+	)
+	return repo, err
+}
+
+func listOrgRepositories(ctx context.Context, credentials *Credentials, org string) ([]*github.Repository, error) {
+	tokenManager, err := credentials.ForOwner(ctx, org)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get credentials")
+	}
+	repos := []*github.Repository{}
+	opt := &github.RepositoryListByOrgOptions{
+		ListOptions: github.ListOptions{PerPage: orgReposPerPage},
+	}
+	for {
+		page, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.Repository, *github.Response, error) {
+				return client.Repositories.ListByOrg(ctx, org, opt)
+			},
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list organization repositories")
+		}
+		repos = append(repos, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return repos, nil
+}
@@ -0,0 +1,59 @@
+package github
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/jobs"
+)
+
+// Source syncs the repositories of a GitHub instance on the schedule of its config, through jobs on the queue.
+type Source struct {
+	config      *config.GitHubSource
+	credentials *Credentials
+	queue       *jobs.Queue
+}
+
+func NewSource(source *config.GitHubSource, queue *jobs.Queue) (*Source, error) {
+	credentials, err := NewCredentials(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create GitHub credentials for source %s", source.Name)
+	}
+	return &Source{config: source, credentials: credentials, queue: queue}, nil
+}
+
+func (s *Source) Name() string {
+	return s.config.Name
+}
+
+func (s *Source) Schedule() string {
+	return s.config.Schedule
+}
+
+func (s *Source) Sync(ctx context.Context, db *sqlx.DB) error {
+	return Sync(ctx, db, s.config, s.credentials, s.queue)
+}
+
+func (s *Source) WebhooksEnabled() bool {
+	return s.config.WebhookSecret != ""
+}
+
+func (s *Source) WebhookPath() string {
+	if s.config.Name == config.DefaultGitHubSourceName {
+		return "/webhooks/github"
+	}
+	return "/webhooks/github/" + s.config.Name
+}
+
+func (s *Source) WebhookHandler(ctx context.Context, db *sqlx.DB) http.Handler {
+	return NewWebhookHandler(ctx, db, s.config, s.queue)
+}
+
+// Exhausted is whether every token of the source is rate limited.
+func (s *Source) Exhausted() bool {
+	return s.credentials.Exhausted()
+}
@@ -11,8 +11,10 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/jobs"
 	"github.com/ilaif/athena-cycle/syncer/internal/logging"
 	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
 )
 
 const (
@@ -22,36 +24,82 @@ const (
 	issueEventsPerPage = 100
 	prReviewsPerPage   = 100
 	prFilesPerPage     = 100
+	prCommitsPerPage   = 100
+	prCommentsPerPage  = 100
 )
 
-func Sync(ctx context.Context, db *sqlx.DB, repos []config.GitHubRepository, tokens []string) error {
-	log := logging.MustFromContext(ctx)
-	log.Info("Syncing repositories")
-	tokenManager := NewTokenManager(tokens)
+// Sync discovers the repositories of the source and queues a job to sync each of them. The jobs are run by the
+// workers of every replica, a repository that's already queued isn't queued again.
+func Sync(ctx context.Context, db *sqlx.DB, source *config.GitHubSource, credentials *Credentials, queue *jobs.Queue,
+) error {
+	log := logging.MustFromContext(ctx).WithValues("source", source.Name)
+	ctx = logging.NewContext(ctx, log)
+	log.Info("Queueing repositories")
 
-	for _, repoIdentifier := range repos {
-		repoLog := log.WithValues("repo", repoIdentifier)
-		repoCtx := logging.NewContext(ctx, repoLog)
-		repoLog.Info("Syncing repository", "repo", repoIdentifier)
+	// Repositories that were discovered are synced even when others failed to be discovered
+	repos, discoverErr := discoverRepositories(ctx, db, credentials, newRepositorySelector(source))
+	if discoverErr != nil && len(repos) == 0 {
+		return errors.Wrap(discoverErr, "failed to discover repositories")
+	}
 
-		repo, _, err := getEntity(repoCtx, tokenManager,
-			func(ctx context.Context, client *github.Client) (*github.Repository, *github.Response, error) {
-				return client.Repositories.Get(ctx, repoIdentifier.Owner(), repoIdentifier.Name())
-			},
-		)
-		if err != nil {
-			return errors.Wrap(err, "failed to get repository")
+	// The run of the sync finishes once its jobs are done, each job records its repository in the run
+	syncrun.WaitForJobs(ctx)
+	for _, repo := range repos {
+		payload := jobPayload{Source: source.Name, Repo: repo.GetFullName()}
+		if err := enqueueRunJob(ctx, db, queue, JobKindRepoSync, payload); err != nil {
+			return errors.Wrapf(err, "failed to queue repository %s", repo.GetFullName())
 		}
+	}
 
-		if err := syncRepoPullRequests(repoCtx, db, tokenManager, repo); err != nil {
-			repoLog.Error(err, "Failed to sync pull requests", "repo", repo.GetName())
-		}
+	log.Info("Queued repositories", "repos", len(repos))
+	return errors.Wrap(discoverErr, "failed to discover some repositories")
+}
+
+// syncRepo syncs the entities of a repository, the errors of each entity are recorded in the repository's sync run
+// without stopping the sync of the other entities.
+func syncRepo(ctx context.Context, db *sqlx.DB, source *config.GitHubSource, credentials *Credentials,
+	repo *github.Repository, repoRun *syncrun.Repo,
+) {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing repository")
 
-		repoLog.Info("Synced repository")
+	tokenManager, err := credentials.ForOwner(ctx, repo.GetOwner().GetLogin())
+	if err != nil {
+		log.Error(err, "Failed to get credentials", "repo", repo.GetName())
+		repoRun.Fail(errors.Wrap(err, "failed to get credentials"))
+		return
 	}
 
-	log.Info("Synced repositories")
-	return nil
+	entities := []struct {
+		name    string
+		enabled bool
+		sync    func(context.Context, *sqlx.DB, *TokenManager, *github.Repository) error
+	}{
+		{"pull requests", true, pullRequestSyncer(source)},
+		{"workflow runs", source.SyncWorkflowRuns, syncRepoWorkflowRuns},
+		{"deployments", source.SyncDeployments, syncRepoDeployments},
+		{"releases", source.SyncReleases, syncRepoReleases},
+		{"issues", source.SyncIssues, syncRepoIssues},
+	}
+	for _, entity := range entities {
+		if !entity.enabled {
+			continue
+		}
+		if err := entity.sync(ctx, db, tokenManager, repo); err != nil {
+			log.Error(err, "Failed to sync "+entity.name, "repo", repo.GetName())
+			repoRun.Fail(errors.Wrapf(err, "failed to sync %s", entity.name))
+		}
+	}
+
+	log.Info("Synced repository")
+}
+
+func pullRequestSyncer(source *config.GitHubSource,
+) func(context.Context, *sqlx.DB, *TokenManager, *github.Repository) error {
+	if source.SyncBackend == config.GitHubSyncBackendGraphQL {
+		return syncRepoPullRequestsGraphQL
+	}
+	return syncRepoPullRequests
 }
 
 func syncRepoPullRequests(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
@@ -60,7 +108,7 @@ func syncRepoPullRequests(ctx context.Context, db *sqlx.DB, tokenManager *TokenM
 	log := logging.MustFromContext(ctx)
 	log.Info("Syncing pull requests")
 
-	lastSynced, err := pg.GetLastSyncAt(ctx, db, repo.GetFullName())
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityPullRequests)
 	if err != nil {
 		return err
 	}
@@ -131,7 +179,7 @@ func syncRepoPullRequests(ctx context.Context, db *sqlx.DB, tokenManager *TokenM
 		} else {
 			latestPr = pullRequests[len(pullRequests)-1]
 			log.Info("Updating last synced time", "last_synced", latestPr.UpdatedAt)
-			if err := pg.UpdateLastSyncAt(ctx, db, repo.GetFullName(), latestPr.UpdatedAt); err != nil {
+			if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityPullRequests, latestPr.UpdatedAt); err != nil {
 				return errors.Wrap(err, "failed to update last synced time")
 			}
 		}
@@ -149,7 +197,7 @@ func syncRepoPullRequests(ctx context.Context, db *sqlx.DB, tokenManager *TokenM
 	}
 
 	if latestPr != nil {
-		if err := pg.UpdateLastSyncAt(ctx, db, repo.GetFullName(), latestPr.UpdatedAt); err != nil {
+		if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityPullRequests, latestPr.UpdatedAt); err != nil {
 			return errors.Wrap(err, "failed to update last synced time")
 		}
 	}
@@ -161,6 +209,7 @@ func syncPullRequestsChunk(ctx context.Context, db *sqlx.DB, tokenManager *Token
 	repo *github.Repository, prs []*github.PullRequest,
 ) ([]*pullRequest, error) {
 	prChan := make(chan *pullRequest, len(prs))
+	cycleTimeChan := make(chan *pullRequestCycleTime, len(prs))
 	sem := make(chan struct{}, prSyncConcurrency)
 	eg := errgroup.Group{}
 	for _, pr := range prs {
@@ -168,32 +217,28 @@ func syncPullRequestsChunk(ctx context.Context, db *sqlx.DB, tokenManager *Token
 		sem <- struct{}{}
 		eg.Go(func() error {
 			defer func() { <-sem }()
-			var mergedAt *time.Time
-			if pr.MergedAt != nil {
-				mergedAt = &pr.MergedAt.Time
-			}
-			pullRequest := &pullRequest{
-				PrID:      int(pr.GetID()),
-				RepoID:    int(repo.GetID()),
-				Repo:      repo.GetFullName(),
-				Number:    pr.GetNumber(),
-				Username:  pr.GetUser().GetLogin(),
-				Title:     pr.GetTitle(),
-				Body:      pr.Body,
-				State:     pr.GetState(),
-				Draft:     pr.GetDraft(),
-				MergedAt:  mergedAt,
-				CreatedAt: pr.GetCreatedAt().Time,
-				UpdatedAt: pr.GetUpdatedAt().Time,
-				Data:      pr,
-			}
-			if err := enrichPullRequest(ctx, tokenManager, repo, pullRequest); err != nil {
+			pullRequest := newPullRequest(repo, pr)
+			if err := enrichPullRequest(ctx, db, tokenManager, repo, pullRequest); err != nil {
 				return errors.Wrap(err, "failed to enrich pull request")
 			}
-			if err := syncPullRequestReviews(ctx, db, tokenManager, repo, pullRequest); err != nil {
+			if err := syncPullRequestEvents(ctx, db, tokenManager, repo, pullRequest); err != nil {
+				return errors.Wrap(err, "failed to sync pull request events")
+			}
+			reviews, err := syncPullRequestReviews(ctx, db, tokenManager, repo, pullRequest)
+			if err != nil {
 				return errors.Wrap(err, "failed to sync pull request reviews")
 			}
+			if err := syncPullRequestCommits(ctx, db, tokenManager, repo, pullRequest); err != nil {
+				return errors.Wrap(err, "failed to sync pull request commits")
+			}
+			if err := syncPullRequestComments(ctx, db, tokenManager, repo, pullRequest); err != nil {
+				return errors.Wrap(err, "failed to sync pull request comments")
+			}
+			if err := syncPullRequestChecks(ctx, db, tokenManager, repo, pullRequest); err != nil {
+				return errors.Wrap(err, "failed to sync pull request checks")
+			}
 			prChan <- pullRequest
+			cycleTimeChan <- newPullRequestCycleTime(pullRequest, reviews)
 			return nil
 		})
 	}
@@ -201,17 +246,25 @@ func syncPullRequestsChunk(ctx context.Context, db *sqlx.DB, tokenManager *Token
 		return nil, errors.Wrap(err, "failed to enrich pull request chunk")
 	}
 	close(prChan)
+	close(cycleTimeChan)
 	pullRequests := make([]*pullRequest, 0, len(prs))
 	for pr := range prChan {
 		pullRequests = append(pullRequests, pr)
 	}
+	cycleTimes := make([]*pullRequestCycleTime, 0, len(prs))
+	for cycleTime := range cycleTimeChan {
+		cycleTimes = append(cycleTimes, cycleTime)
+	}
 	if err := upsertPullRequests(ctx, db, pullRequests); err != nil {
 		return nil, errors.Wrap(err, "failed to insert pull requests")
 	}
+	if err := upsertPullRequestCycleTimes(ctx, db, cycleTimes); err != nil {
+		return nil, errors.Wrap(err, "failed to insert pull request cycle times")
+	}
 	return pullRequests, nil
 }
 
-func enrichPullRequest(ctx context.Context, tokenManager *TokenManager,
+func enrichPullRequest(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
 	repo *github.Repository, pr *pullRequest,
 ) error {
 	log := logging.MustFromContext(ctx).WithValues("pr", pr.Number)
@@ -219,43 +272,39 @@ func enrichPullRequest(ctx context.Context, tokenManager *TokenManager,
 
 	log.Info("Enriching pull request", "pr", pr.Number, "pr_updated_at", pr.UpdatedAt)
 
-	lastReadyForReviewEvent, err := getLastReadyForReviewEvent(ctx, tokenManager, repo, pr.Number)
-	if err != nil {
-		return errors.Wrap(err, "failed to get last ready for review event")
-	}
-	if lastReadyForReviewEvent != nil {
-		log.Info("Found last ready for review event", "event", lastReadyForReviewEvent)
-		pr.LastReadyForReviewAt = &lastReadyForReviewEvent.CreatedAt.Time
-	}
-
-	additions, deletions, numberOfChangedFiles, err := getPRFileChanges(ctx, tokenManager, repo, pr.Number)
+	files, err := listPullRequestFiles(ctx, tokenManager, repo, pr)
 	if err != nil {
 		return errors.Wrap(err, "failed to get pull request file changes")
 	}
-	pr.Additions, pr.Deletions, pr.ChangedFiles = additions, deletions, numberOfChangedFiles
+	if err := replacePullRequestFiles(ctx, db, pr, files); err != nil {
+		return errors.Wrap(err, "failed to insert pull request files")
+	}
+	pr.Additions = lo.SumBy(files, func(file *pullRequestFile) int { return file.Additions })
+	pr.Deletions = lo.SumBy(files, func(file *pullRequestFile) int { return file.Deletions })
+	pr.ChangedFiles = len(files)
 
 	return nil
 }
 
-func getLastReadyForReviewEvent(ctx context.Context, tokenManager *TokenManager,
-	repo *github.Repository, prNumber int,
-) (*github.IssueEvent, error) {
-	var lastReadyForReviewEvent *github.IssueEvent
+func syncPullRequestEvents(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, pr *pullRequest,
+) error {
+	log := logging.MustFromContext(ctx).WithValues("pr", pr.Number)
+
+	events := []*pullRequestEvent{}
 	opt := &github.ListOptions{PerPage: issueEventsPerPage}
 	for {
-		// default order is desc so we get the latest events first
 		prEvents, resp, err := listEntities(ctx, tokenManager,
 			func(ctx context.Context, client *github.Client) ([]*github.IssueEvent, *github.Response, error) {
-				return client.Issues.ListIssueEvents(ctx, *repo.Owner.Login, *repo.Name, prNumber, opt)
+				return client.Issues.ListIssueEvents(ctx, *repo.Owner.Login, *repo.Name, pr.Number, opt)
 			},
 		)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to list issue events")
+			return errors.Wrap(err, "failed to list issue events")
 		}
 		for _, event := range prEvents {
-			if event.GetEvent() == "ready_for_review" {
-				lastReadyForReviewEvent = event
-				break
+			if lo.Contains(pullRequestEventTypes, event.GetEvent()) {
+				events = append(events, newPullRequestEvent(repo, pr.PrID, event))
 			}
 		}
 		if resp.NextPage == 0 {
@@ -263,35 +312,45 @@ func getLastReadyForReviewEvent(ctx context.Context, tokenManager *TokenManager,
 		}
 		opt.Page = resp.NextPage
 	}
-	return lastReadyForReviewEvent, nil
+
+	if err := replacePullRequestEvents(ctx, db, pr, events); err != nil {
+		return errors.Wrap(err, "failed to insert pull request events")
+	}
+	pr.LastReadyForReviewAt = lastReadyForReviewAt(events)
+	if pr.LastReadyForReviewAt != nil {
+		log.Info("Found last ready for review event", "last_ready_for_review_at", pr.LastReadyForReviewAt)
+	}
+	return nil
 }
 
-func getPRFileChanges(ctx context.Context, tokenManager *TokenManager, repo *github.Repository, prNumber int) (int, int, int, error) {
-	additions, deletions, numberOfFiles := 0, 0, 0
+func listPullRequestFiles(ctx context.Context, tokenManager *TokenManager, repo *github.Repository, pr *pullRequest,
+) ([]*pullRequestFile, error) {
+	files := []*pullRequestFile{}
 	opts := &github.ListOptions{PerPage: prFilesPerPage}
 	for {
-		files, res, err := listEntities(ctx, tokenManager,
+		commitFiles, res, err := listEntities(ctx, tokenManager,
 			func(ctx context.Context, client *github.Client) ([]*github.CommitFile, *github.Response, error) {
-				return client.PullRequests.ListFiles(ctx, *repo.Owner.Login, *repo.Name, prNumber, opts)
+				return client.PullRequests.ListFiles(ctx, *repo.Owner.Login, *repo.Name, pr.Number, opts)
 			},
 		)
 		if err != nil {
-			return 0, 0, 0, errors.Wrap(err, "failed to get pull request files")
+			return nil, errors.Wrap(err, "failed to get pull request files")
 		}
-		for _, file := range files {
-			additions += file.GetAdditions()
-			deletions += file.GetDeletions()
+		for _, file := range commitFiles {
+			files = append(files, newPullRequestFile(repo, pr.PrID, file))
 		}
-		numberOfFiles += len(files)
 		if res.NextPage == 0 {
 			break
 		}
 		opts.Page = res.NextPage
 	}
-	return additions, deletions, numberOfFiles, nil
+	return files, nil
 }
 
-func syncPullRequestReviews(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository, pr *pullRequest) error {
+func syncPullRequestReviews(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository,
+	pr *pullRequest,
+) ([]*pullRequestReview, error) {
+	prReviews := []*pullRequestReview{}
 	opt := &github.ListOptions{PerPage: prReviewsPerPage}
 	for {
 		// default order is desc so we get the latest events first
@@ -301,28 +360,106 @@ func syncPullRequestReviews(ctx context.Context, db *sqlx.DB, tokenManager *Toke
 			},
 		)
 		if err != nil {
-			return errors.Wrap(err, "failed to list issue events")
+			return nil, errors.Wrap(err, "failed to list issue events")
 		}
-		if err := upsertPullRequestReviews(ctx, db,
-			lo.Map(reviews, func(review *github.PullRequestReview, _ int) *pullRequestReview {
-				return &pullRequestReview{
-					ReviewID:    int(review.GetID()),
-					PrID:        pr.PrID,
-					Repo:        repo.GetFullName(),
-					Username:    review.GetUser().GetLogin(),
-					State:       review.GetState(),
-					SubmittedAt: review.GetSubmittedAt().Time,
-					CommitID:    review.GetCommitID(),
-					Data:        review,
-				}
-			}),
-		); err != nil {
-			return errors.Wrap(err, "failed to insert pull requests")
+		pageReviews := lo.Map(reviews, func(review *github.PullRequestReview, _ int) *pullRequestReview {
+			return newPullRequestReview(repo, pr.PrID, review)
+		})
+		if err := upsertPullRequestReviews(ctx, db, pageReviews); err != nil {
+			return nil, errors.Wrap(err, "failed to insert pull requests")
 		}
+		prReviews = append(prReviews, pageReviews...)
 		if resp.NextPage == 0 {
 			break
 		}
 		opt.Page = resp.NextPage
 	}
+	return prReviews, nil
+}
+
+// syncPullRequestCommits replaces the stored commits of a pull request with the commits it has now, and derives its
+// first commit time from them.
+func syncPullRequestCommits(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository, pr *pullRequest) error {
+	prCommits := []*pullRequestCommit{}
+	opt := &github.ListOptions{PerPage: prCommitsPerPage}
+	for {
+		commits, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.RepositoryCommit, *github.Response, error) {
+				return client.PullRequests.ListCommits(ctx, *repo.Owner.Login, *repo.Name, pr.Number, opt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list pull request commits")
+		}
+		prCommits = append(prCommits, lo.Map(commits, func(commit *github.RepositoryCommit, _ int) *pullRequestCommit {
+			return newPullRequestCommit(repo, pr.PrID, commit)
+		})...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if err := replacePullRequestCommits(ctx, db, pr, prCommits); err != nil {
+		return errors.Wrap(err, "failed to insert pull request commits")
+	}
+	pr.FirstCommitAt = firstCommitAt(nil, prCommits)
 	return nil
 }
+
+// syncPullRequestComments syncs both the inline review comments and the conversation comments of a pull request, and
+// deletes the stored comments that were deleted on GitHub.
+func syncPullRequestComments(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository, pr *pullRequest) error {
+	reviewCommentIDs := []int{}
+	reviewCommentsOpt := &github.PullRequestListCommentsOptions{ListOptions: github.ListOptions{PerPage: prCommentsPerPage}}
+	for {
+		comments, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.PullRequestComment, *github.Response, error) {
+				return client.PullRequests.ListComments(ctx, *repo.Owner.Login, *repo.Name, pr.Number, reviewCommentsOpt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list pull request review comments")
+		}
+		reviewComments := lo.Map(comments, func(comment *github.PullRequestComment, _ int) *pullRequestReviewComment {
+			return newPullRequestReviewComment(repo, pr.PrID, comment)
+		})
+		if err := upsertPullRequestReviewComments(ctx, db, reviewComments); err != nil {
+			return errors.Wrap(err, "failed to insert pull request review comments")
+		}
+		reviewCommentIDs = append(reviewCommentIDs, lo.Map(reviewComments, func(c *pullRequestReviewComment, _ int) int {
+			return c.CommentID
+		})...)
+		if resp.NextPage == 0 {
+			break
+		}
+		reviewCommentsOpt.Page = resp.NextPage
+	}
+	if err := deletePullRequestCommentsExcept(ctx, db, "pull_request_review_comments", pr, reviewCommentIDs); err != nil {
+		return err
+	}
+
+	commentIDs := []int{}
+	commentsOpt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: prCommentsPerPage}}
+	for {
+		comments, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.IssueComment, *github.Response, error) {
+				return client.Issues.ListComments(ctx, *repo.Owner.Login, *repo.Name, pr.Number, commentsOpt)
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list pull request comments")
+		}
+		prComments := lo.Map(comments, func(comment *github.IssueComment, _ int) *pullRequestComment {
+			return newPullRequestComment(repo, pr.PrID, comment)
+		})
+		if err := upsertPullRequestComments(ctx, db, prComments); err != nil {
+			return errors.Wrap(err, "failed to insert pull request comments")
+		}
+		commentIDs = append(commentIDs, lo.Map(prComments, func(c *pullRequestComment, _ int) int { return c.CommentID })...)
+		if resp.NextPage == 0 {
+			break
+		}
+		commentsOpt.Page = resp.NextPage
+	}
+	return deletePullRequestCommentsExcept(ctx, db, "pull_request_comments", pr, commentIDs)
+}
@@ -0,0 +1,386 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+)
+
+const (
+	graphQLPRsPerPage            = 50
+	graphQLReviewsPerPage        = 100
+	graphQLCommitsPerPage        = 100
+	graphQLTimelineItemsPerPage  = 100
+	graphQLFilesPerPage          = 100
+	graphQLLabelsPerPage         = 100
+	graphQLAssigneesPerPage      = 10 // GitHub allows up to 10 assignees
+	graphQLReviewRequestsPerPage = 100
+)
+
+func syncRepoPullRequestsGraphQL(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository,
+) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing pull requests using GraphQL")
+
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityPullRequests)
+	if err != nil {
+		return err
+	}
+
+	direction := githubv4.OrderDirectionAsc
+	if lastSynced != nil {
+		log.Info("Last synced time found, syncing from latest to last sync time", "last_synced", lastSynced)
+		direction = githubv4.OrderDirectionDesc
+	} else {
+		log.Info("No last synced time found, starting from the first page")
+	}
+
+	variables := map[string]interface{}{
+		"owner":                 githubv4.String(repo.GetOwner().GetLogin()),
+		"name":                  githubv4.String(repo.GetName()),
+		"prsPerPage":            githubv4.Int(graphQLPRsPerPage),
+		"reviewsPerPage":        githubv4.Int(graphQLReviewsPerPage),
+		"commitsPerPage":        githubv4.Int(graphQLCommitsPerPage),
+		"cursor":                (*githubv4.String)(nil),
+		"timelineItemsPerPage":  githubv4.Int(graphQLTimelineItemsPerPage),
+		"timelineItemTypes":     graphQLTimelineItemTypes,
+		"filesPerPage":          githubv4.Int(graphQLFilesPerPage),
+		"labelsPerPage":         githubv4.Int(graphQLLabelsPerPage),
+		"assigneesPerPage":      githubv4.Int(graphQLAssigneesPerPage),
+		"reviewRequestsPerPage": githubv4.Int(graphQLReviewRequestsPerPage),
+		"orderBy": githubv4.IssueOrder{
+			Field:     githubv4.IssueOrderFieldUpdatedAt,
+			Direction: direction,
+		},
+	}
+
+	var latestPr *pullRequest
+
+	for {
+		var query struct {
+			Repository struct {
+				PullRequests struct {
+					Nodes    []graphQLPullRequest
+					PageInfo graphQLPageInfo
+				} `graphql:"pullRequests(first: $prsPerPage, after: $cursor, orderBy: $orderBy)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+			RateLimit graphQLRateLimit
+		}
+		if err := queryGraphQL(ctx, tokenManager, &query, variables); err != nil {
+			return errors.Wrap(err, "failed to query pull requests")
+		}
+		log.V(1).Info("Rate limit remaining", "remaining", query.RateLimit.Remaining)
+		prs := query.Repository.PullRequests.Nodes
+		pageInfo := query.Repository.PullRequests.PageInfo
+
+		// Filter out pull requests that were already synced
+		prsToSync := lo.Filter(prs, func(pr graphQLPullRequest, _ int) bool {
+			syncEarliestTime := time.Now().UTC().Add(-syncBackTime)
+			if pr.UpdatedAt.Before(syncEarliestTime) {
+				return false
+			}
+			if lastSynced == nil {
+				return true
+			}
+			return pr.UpdatedAt.After(*lastSynced)
+		})
+		if len(prsToSync) == 0 {
+			if direction == githubv4.OrderDirectionDesc || len(prs) == 0 {
+				log.Info("No new pull requests found")
+				break
+			}
+
+			lastPr := prs[len(prs)-1]
+			log.Info("Skipped all pull requests in page, moving to next page", "last_pr_updated_at", lastPr.UpdatedAt)
+			if !pageInfo.HasNextPage {
+				break
+			}
+			variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+			continue
+		}
+
+		pullRequests, err := syncPullRequestsChunkGraphQL(ctx, db, tokenManager, repo, prsToSync)
+		if err != nil {
+			return errors.Wrap(err, "failed to sync pull requests chunk")
+		}
+		log.Info("Synced pull requests", "prs", len(pullRequests), "last_pr_updated_at", pullRequests[0].UpdatedAt)
+
+		if direction == githubv4.OrderDirectionDesc { // If we're syncing in descending order, we need to stop when we reach the last synced time
+			if latestPr == nil {
+				latestPr = pullRequests[0] // The latest PR is the first one in the first page
+			}
+		} else {
+			latestPr = pullRequests[len(pullRequests)-1]
+			log.Info("Updating last synced time", "last_synced", latestPr.UpdatedAt)
+			if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityPullRequests, latestPr.UpdatedAt); err != nil {
+				return errors.Wrap(err, "failed to update last synced time")
+			}
+		}
+
+		if direction == githubv4.OrderDirectionDesc {
+			if len(prsToSync) < len(prs) { // If we filtered, it means we reached the last page
+				log.Info("Reached last synced time", "last_synced", lastSynced)
+				break
+			}
+		}
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+	}
+
+	if latestPr != nil {
+		if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityPullRequests, latestPr.UpdatedAt); err != nil {
+			return errors.Wrap(err, "failed to update last synced time")
+		}
+	}
+
+	return nil
+}
+
+func syncPullRequestsChunkGraphQL(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, prs []graphQLPullRequest,
+) ([]*pullRequest, error) {
+	pullRequests := make([]*pullRequest, 0, len(prs))
+	cycleTimes := make([]*pullRequestCycleTime, 0, len(prs))
+	for i := range prs {
+		pr := &prs[i]
+		pullRequest := newPullRequestFromGraphQL(repo, pr)
+		pullRequests = append(pullRequests, pullRequest)
+
+		prReviews := pr.Reviews.Nodes
+		if pr.Reviews.PageInfo.HasNextPage {
+			moreReviews, err := listPullRequestReviewsGraphQL(ctx, tokenManager, repo, pr.Number, pr.Reviews.PageInfo.EndCursor)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to list pull request reviews")
+			}
+			prReviews = append(prReviews, moreReviews...)
+		}
+		pullRequestReviews := make([]*pullRequestReview, 0, len(prReviews))
+		for j := range prReviews {
+			pullRequestReviews = append(pullRequestReviews, newPullRequestReviewFromGraphQL(repo, pullRequest, &prReviews[j]))
+		}
+		if err := upsertPullRequestReviews(ctx, db, pullRequestReviews); err != nil {
+			return nil, errors.Wrap(err, "failed to insert pull request reviews")
+		}
+
+		prCommits := pr.Commits.Nodes
+		if pr.Commits.PageInfo.HasNextPage {
+			moreCommits, err := listPullRequestCommitsGraphQL(ctx, tokenManager, repo, pr.Number, pr.Commits.PageInfo.EndCursor)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to list pull request commits")
+			}
+			prCommits = append(prCommits, moreCommits...)
+		}
+		pullRequestCommits := make([]*pullRequestCommit, 0, len(prCommits))
+		for j := range prCommits {
+			pullRequestCommits = append(pullRequestCommits, newPullRequestCommitFromGraphQL(repo, pullRequest.PrID, &prCommits[j]))
+		}
+		pullRequest.FirstCommitAt = firstCommitAt(nil, pullRequestCommits)
+		// Commits are replaced per pull request like with REST, a chunk's commits could exceed the bind parameter limit
+		if err := replacePullRequestCommits(ctx, db, pullRequest, pullRequestCommits); err != nil {
+			return nil, errors.Wrap(err, "failed to insert pull request commits")
+		}
+
+		timelineItems := pr.TimelineItems.Nodes
+		if pr.TimelineItems.PageInfo.HasNextPage {
+			moreTimelineItems, err := listPullRequestTimelineItemsGraphQL(ctx, tokenManager, repo, pr.Number,
+				pr.TimelineItems.PageInfo.EndCursor)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to list pull request timeline items")
+			}
+			timelineItems = append(timelineItems, moreTimelineItems...)
+		}
+		events := make([]*pullRequestEvent, 0, len(timelineItems))
+		for j := range timelineItems {
+			events = append(events, newPullRequestEventFromGraphQL(repo, pullRequest.PrID, &timelineItems[j]))
+		}
+		if err := replacePullRequestEvents(ctx, db, pullRequest, events); err != nil {
+			return nil, errors.Wrap(err, "failed to insert pull request events")
+		}
+		pullRequest.LastReadyForReviewAt = lastReadyForReviewAt(events)
+
+		prFiles := pr.Files.Nodes
+		if pr.Files.PageInfo.HasNextPage {
+			moreFiles, err := listPullRequestFilesGraphQL(ctx, tokenManager, repo, pr.Number, pr.Files.PageInfo.EndCursor)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to list pull request files")
+			}
+			prFiles = append(prFiles, moreFiles...)
+		}
+		files := make([]*pullRequestFile, 0, len(prFiles))
+		for j := range prFiles {
+			files = append(files, newPullRequestFileFromGraphQL(repo, pullRequest.PrID, &prFiles[j]))
+		}
+		// GraphQL has no previous filename for renamed files, so pull requests with renames list their files with REST
+		if lo.ContainsBy(prFiles, func(f graphQLPullRequestFile) bool { return f.ChangeType == githubv4.PatchStatusRenamed }) {
+			var err error
+			if files, err = listPullRequestFiles(ctx, tokenManager, repo, pullRequest); err != nil {
+				return nil, errors.Wrap(err, "failed to list renamed pull request files")
+			}
+		}
+		if err := replacePullRequestFiles(ctx, db, pullRequest, files); err != nil {
+			return nil, errors.Wrap(err, "failed to insert pull request files")
+		}
+
+		// Review comments are nested in review threads and checks in commits, which are too expensive to batch in the
+		// pull requests query
+		if err := syncPullRequestComments(ctx, db, tokenManager, repo, pullRequest); err != nil {
+			return nil, errors.Wrap(err, "failed to sync pull request comments")
+		}
+		if err := syncPullRequestChecks(ctx, db, tokenManager, repo, pullRequest); err != nil {
+			return nil, errors.Wrap(err, "failed to sync pull request checks")
+		}
+
+		cycleTimes = append(cycleTimes, newPullRequestCycleTime(pullRequest, pullRequestReviews))
+	}
+	if err := upsertPullRequests(ctx, db, pullRequests); err != nil {
+		return nil, errors.Wrap(err, "failed to insert pull requests")
+	}
+	if err := upsertPullRequestCycleTimes(ctx, db, cycleTimes); err != nil {
+		return nil, errors.Wrap(err, "failed to insert pull request cycle times")
+	}
+	return pullRequests, nil
+}
+
+func listPullRequestReviewsGraphQL(ctx context.Context, tokenManager *TokenManager,
+	repo *github.Repository, prNumber int, cursor githubv4.String,
+) ([]graphQLPullRequestReview, error) {
+	variables := map[string]interface{}{
+		"owner":          githubv4.String(repo.GetOwner().GetLogin()),
+		"name":           githubv4.String(repo.GetName()),
+		"number":         githubv4.Int(prNumber),
+		"reviewsPerPage": githubv4.Int(graphQLReviewsPerPage),
+		"cursor":         githubv4.NewString(cursor),
+	}
+
+	reviews := []graphQLPullRequestReview{}
+	for {
+		var query struct {
+			Repository struct {
+				PullRequest struct {
+					Reviews graphQLPullRequestReviews `graphql:"reviews(first: $reviewsPerPage, after: $cursor)"`
+				} `graphql:"pullRequest(number: $number)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+		}
+		if err := queryGraphQL(ctx, tokenManager, &query, variables); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull request reviews")
+		}
+		reviews = append(reviews, query.Repository.PullRequest.Reviews.Nodes...)
+		pageInfo := query.Repository.PullRequest.Reviews.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+	}
+	return reviews, nil
+}
+
+func listPullRequestCommitsGraphQL(ctx context.Context, tokenManager *TokenManager,
+	repo *github.Repository, prNumber int, cursor githubv4.String,
+) ([]graphQLPullRequestCommit, error) {
+	variables := map[string]interface{}{
+		"owner":          githubv4.String(repo.GetOwner().GetLogin()),
+		"name":           githubv4.String(repo.GetName()),
+		"number":         githubv4.Int(prNumber),
+		"commitsPerPage": githubv4.Int(graphQLCommitsPerPage),
+		"cursor":         githubv4.NewString(cursor),
+	}
+
+	commits := []graphQLPullRequestCommit{}
+	for {
+		var query struct {
+			Repository struct {
+				PullRequest struct {
+					Commits graphQLPullRequestCommits `graphql:"commits(first: $commitsPerPage, after: $cursor)"`
+				} `graphql:"pullRequest(number: $number)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+		}
+		if err := queryGraphQL(ctx, tokenManager, &query, variables); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull request commits")
+		}
+		commits = append(commits, query.Repository.PullRequest.Commits.Nodes...)
+		pageInfo := query.Repository.PullRequest.Commits.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+	}
+	return commits, nil
+}
+
+func listPullRequestTimelineItemsGraphQL(ctx context.Context, tokenManager *TokenManager,
+	repo *github.Repository, prNumber int, cursor githubv4.String,
+) ([]graphQLTimelineItem, error) {
+	variables := map[string]interface{}{
+		"owner":                githubv4.String(repo.GetOwner().GetLogin()),
+		"name":                 githubv4.String(repo.GetName()),
+		"number":               githubv4.Int(prNumber),
+		"timelineItemsPerPage": githubv4.Int(graphQLTimelineItemsPerPage),
+		"timelineItemTypes":    graphQLTimelineItemTypes,
+		"cursor":               githubv4.NewString(cursor),
+	}
+
+	items := []graphQLTimelineItem{}
+	for {
+		var query struct {
+			Repository struct {
+				PullRequest struct {
+					TimelineItems graphQLTimelineItems `graphql:"timelineItems(first: $timelineItemsPerPage, after: $cursor, itemTypes: $timelineItemTypes)"`
+				} `graphql:"pullRequest(number: $number)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+		}
+		if err := queryGraphQL(ctx, tokenManager, &query, variables); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull request timeline items")
+		}
+		items = append(items, query.Repository.PullRequest.TimelineItems.Nodes...)
+		pageInfo := query.Repository.PullRequest.TimelineItems.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+	}
+	return items, nil
+}
+
+func listPullRequestFilesGraphQL(ctx context.Context, tokenManager *TokenManager,
+	repo *github.Repository, prNumber int, cursor githubv4.String,
+) ([]graphQLPullRequestFile, error) {
+	variables := map[string]interface{}{
+		"owner":        githubv4.String(repo.GetOwner().GetLogin()),
+		"name":         githubv4.String(repo.GetName()),
+		"number":       githubv4.Int(prNumber),
+		"filesPerPage": githubv4.Int(graphQLFilesPerPage),
+		"cursor":       githubv4.NewString(cursor),
+	}
+
+	files := []graphQLPullRequestFile{}
+	for {
+		var query struct {
+			Repository struct {
+				PullRequest struct {
+					Files graphQLPullRequestFiles `graphql:"files(first: $filesPerPage, after: $cursor)"`
+				} `graphql:"pullRequest(number: $number)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+		}
+		if err := queryGraphQL(ctx, tokenManager, &query, variables); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull request files")
+		}
+		files = append(files, query.Repository.PullRequest.Files.Nodes...)
+		pageInfo := query.Repository.PullRequest.Files.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pageInfo.EndCursor)
+	}
+	return files, nil
+}
@@ -1,40 +1,89 @@
 package github
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/pkg/errors"
 )
 
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+	// label names the token in metrics, it's stable across restarts and refreshes of the token
+	label() string
+}
+
+// staticToken is a personal access token, labeled by its index in the configured tokens.
+type staticToken struct {
+	token string
+	index int
+}
+
+func (t staticToken) Token(context.Context) (string, error) {
+	return t.token, nil
+}
+
+func (t staticToken) label() string {
+	return fmt.Sprintf("pat-%d", t.index)
+}
+
+// installationToken is the auto-refreshing token of a GitHub App installation, labeled by the installation ID.
+type installationToken struct {
+	*ghinstallation.Transport
+	installationID int64
+}
+
+func (t installationToken) label() string {
+	return fmt.Sprintf("installation-%d", t.installationID)
+}
+
 type TokenManager struct {
-	tokens    []string
-	index     int
-	exhausted bool
+	endpoint *endpoint
+	tokens   []tokenSource
+	index    int
+	// exhausted is read without the lock, which is held while waiting for the rate limit to reset
+	exhausted atomic.Bool
 	mu        sync.Mutex
 }
 
-func NewTokenManager(tokens []string) *TokenManager {
+func newStaticTokenManager(endpoint *endpoint, tokens []string) *TokenManager {
+	sources := make([]tokenSource, 0, len(tokens))
+	for i, token := range tokens {
+		sources = append(sources, staticToken{token: token, index: i})
+	}
+	return newTokenManager(endpoint, sources)
+}
+
+func newTokenManager(endpoint *endpoint, tokens []tokenSource) *TokenManager {
 	return &TokenManager{
-		tokens:    tokens,
-		index:     0,
-		exhausted: false,
-		mu:        sync.Mutex{},
+		endpoint: endpoint,
+		tokens:   tokens,
+		index:    0,
+		mu:       sync.Mutex{},
 	}
 }
 
-func (tm *TokenManager) GetToken() string {
+// GetToken returns the current token and its label for metrics.
+func (tm *TokenManager) GetToken(ctx context.Context) (token, label string, err error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	if tm.exhausted {
-		return ""
+	label = tm.tokens[tm.index].label()
+	if tm.exhausted.Load() {
+		return "", label, nil
 	}
-	token := tm.tokens[tm.index]
-	return token
+	token, err = tm.tokens[tm.index].Token(ctx)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to get token")
+	}
+	return token, label, nil
 }
 
 func (tm *TokenManager) IsExhausted() bool {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
-	return tm.exhausted
+	return tm.exhausted.Load()
 }
 
 func (tm *TokenManager) RotateToken() {
@@ -42,14 +91,12 @@ func (tm *TokenManager) RotateToken() {
 	defer tm.mu.Unlock()
 	tm.index = (tm.index + 1) % len(tm.tokens)
 	if tm.index == 0 {
-		tm.exhausted = true
+		tm.exhausted.Store(true)
 	}
 }
 
 func (tm *TokenManager) ResetExhaustion() {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
-	tm.exhausted = false
+	tm.exhausted.Store(false)
 }
 
 func (tm *TokenManager) WaitForRateLimitReset(duration time.Duration) {
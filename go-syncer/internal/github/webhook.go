@@ -0,0 +1,213 @@
+package github
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/jobs"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+// maxWebhookPayloadBytes caps the size of a delivery, GitHub caps payloads at 25MB.
+const maxWebhookPayloadBytes = 25 << 20
+
+// WebhookHandler upserts reviews, comments and issues from GitHub webhook deliveries, and queues pr_enrichment jobs to
+// sync their pull requests, so a failed sync is retried. A delivery is only acknowledged once it's handled, a delivery
+// that fails to be handled is answered with a 500 and can be redelivered from GitHub. The periodic sync reconciles
+// anything that was missed.
+type WebhookHandler struct {
+	ctx        context.Context
+	db         *sqlx.DB
+	queue      *jobs.Queue
+	source     string
+	secret     []byte
+	selector   *repositorySelector
+	syncIssues bool
+}
+
+func NewWebhookHandler(ctx context.Context, db *sqlx.DB, source *config.GitHubSource, queue *jobs.Queue,
+) *WebhookHandler {
+	return &WebhookHandler{
+		ctx:        ctx,
+		db:         db,
+		queue:      queue,
+		source:     source.Name,
+		secret:     []byte(source.WebhookSecret),
+		selector:   newRepositorySelector(source),
+		syncIssues: source.SyncIssues,
+	}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logging.MustFromContext(h.ctx).WithValues(
+		"source", h.source,
+		"delivery_id", github.DeliveryID(r),
+		"event", github.WebHookType(r),
+	)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxWebhookPayloadBytes)
+	payload, err := github.ValidatePayloadFromBody(
+		r.Header.Get("Content-Type"), body, r.Header.Get(github.SHA256SignatureHeader), h.secret,
+	)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		log.Error(err, "Webhook payload is too large")
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Error(err, "Failed to validate webhook payload")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		log.Error(err, "Failed to parse webhook payload")
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.handleEvent(logging.NewContext(r.Context(), log), event); err != nil {
+		log.Error(err, "Failed to handle webhook event")
+		http.Error(w, "failed to handle event", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *WebhookHandler) handleEvent(ctx context.Context, event interface{}) error {
+	switch event := event.(type) {
+	case *github.PullRequestEvent:
+		return h.handlePullRequestEvent(ctx, event)
+	case *github.PullRequestReviewEvent:
+		return h.handlePullRequestReviewEvent(ctx, event)
+	case *github.IssuesEvent:
+		return h.handleIssuesEvent(ctx, event)
+	case *github.PullRequestReviewCommentEvent:
+		return h.handlePullRequestReviewCommentEvent(ctx, event)
+	case *github.IssueCommentEvent:
+		return h.handleIssueCommentEvent(ctx, event)
+	default:
+		logging.MustFromContext(ctx).V(1).Info("Ignoring unsupported webhook event")
+		return nil
+	}
+}
+
+func (h *WebhookHandler) handlePullRequestEvent(ctx context.Context, event *github.PullRequestEvent) error {
+	repo := event.GetRepo()
+	if !h.isTracked(ctx, repo) {
+		return nil
+	}
+	log := logging.MustFromContext(ctx)
+	log.Info("Handling pull request webhook", "action", event.GetAction(), "pr", event.GetPullRequest().GetNumber())
+
+	return h.enqueueEnrichment(ctx, repo, event.GetPullRequest().GetNumber())
+}
+
+func (h *WebhookHandler) handlePullRequestReviewEvent(ctx context.Context, event *github.PullRequestReviewEvent) error {
+	repo := event.GetRepo()
+	if !h.isTracked(ctx, repo) {
+		return nil
+	}
+	log := logging.MustFromContext(ctx)
+	log.Info("Handling pull request review webhook", "action", event.GetAction(), "pr", event.GetPullRequest().GetNumber())
+
+	review := newPullRequestReview(repo, int(event.GetPullRequest().GetID()), event.GetReview())
+	if err := upsertPullRequestReviews(ctx, h.db, []*pullRequestReview{review}); err != nil {
+		return errors.Wrap(err, "failed to upsert pull request review")
+	}
+
+	// Re-sync the pull request to recompute its cycle time from all of its reviews
+	return h.enqueueEnrichment(ctx, repo, event.GetPullRequest().GetNumber())
+}
+
+func (h *WebhookHandler) handleIssuesEvent(ctx context.Context, event *github.IssuesEvent) error {
+	repo := event.GetRepo()
+	if !h.isTracked(ctx, repo) {
+		return nil
+	}
+	if !event.GetIssue().IsPullRequest() {
+		return h.handleIssueEvent(ctx, event)
+	}
+	log := logging.MustFromContext(ctx)
+	log.Info("Handling issues webhook", "action", event.GetAction(), "pr", event.GetIssue().GetNumber())
+
+	return h.enqueueEnrichment(ctx, repo, event.GetIssue().GetNumber())
+}
+
+func (h *WebhookHandler) handleIssueEvent(ctx context.Context, event *github.IssuesEvent) error {
+	if !h.syncIssues {
+		return nil
+	}
+	log := logging.MustFromContext(ctx)
+	log.Info("Handling issue webhook", "action", event.GetAction(), "issue", event.GetIssue().GetNumber())
+
+	if err := upsertIssues(ctx, h.db, []*issue{newIssue(event.GetRepo(), event.GetIssue())}); err != nil {
+		return errors.Wrap(err, "failed to upsert issue")
+	}
+	return nil
+}
+
+func (h *WebhookHandler) handlePullRequestReviewCommentEvent(ctx context.Context,
+	event *github.PullRequestReviewCommentEvent,
+) error {
+	repo := event.GetRepo()
+	if !h.isTracked(ctx, repo) {
+		return nil
+	}
+	log := logging.MustFromContext(ctx)
+	log.Info("Handling pull request review comment webhook", "action", event.GetAction(), "pr", event.GetPullRequest().GetNumber())
+
+	if event.GetAction() == "deleted" {
+		return deleteComment(ctx, h.db, "pull_request_review_comments", repoHost(repo), event.GetComment().GetID())
+	}
+
+	comment := newPullRequestReviewComment(repo, int(event.GetPullRequest().GetID()), event.GetComment())
+	if err := upsertPullRequestReviewComments(ctx, h.db, []*pullRequestReviewComment{comment}); err != nil {
+		return errors.Wrap(err, "failed to upsert pull request review comment")
+	}
+	return nil
+}
+
+func (h *WebhookHandler) handleIssueCommentEvent(ctx context.Context, event *github.IssueCommentEvent) error {
+	repo := event.GetRepo()
+	if !h.isTracked(ctx, repo) || !event.GetIssue().IsPullRequest() {
+		return nil
+	}
+	log := logging.MustFromContext(ctx)
+	log.Info("Handling issue comment webhook", "action", event.GetAction(), "pr", event.GetIssue().GetNumber())
+
+	if event.GetAction() == "deleted" {
+		return deleteComment(ctx, h.db, "pull_request_comments", repoHost(repo), event.GetComment().GetID())
+	}
+
+	// The issue payload doesn't include the pull request ID, the comment is synced with its pull request
+	return h.enqueueEnrichment(ctx, repo, event.GetIssue().GetNumber())
+}
+
+func (h *WebhookHandler) enqueueEnrichment(ctx context.Context, repo *github.Repository, number int) error {
+	payload := jobPayload{Source: h.source, Repo: repo.GetFullName(), Number: number}
+	if err := enqueueJob(ctx, h.queue, JobKindPREnrichment, payload); err != nil {
+		return errors.Wrap(err, "failed to queue pull request enrichment")
+	}
+	return nil
+}
+
+func (h *WebhookHandler) isTracked(ctx context.Context, repo *github.Repository) bool {
+	if h.selector.Selected(repo) {
+		return true
+	}
+	logging.MustFromContext(ctx).Info("Ignoring webhook for untracked repository", "repo", repo.GetFullName())
+	return false
+}
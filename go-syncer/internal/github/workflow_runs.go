@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+)
+
+const (
+	workflowRunsPerPage = 100
+	workflowJobsPerPage = 100
+
+	// workflowRunsLookBack is how far back runs are listed again to pick up completions and re-runs. A run that's
+	// still incomplete, or re-run, after it is no longer synced.
+	workflowRunsLookBack = 3 * 24 * time.Hour
+)
+
+// syncRepoWorkflowRuns syncs the workflow runs created since the last sync, newest first, along with their jobs.
+// Runs created within the look-back window are listed again on every sync, so runs that completed or were re-run
+// since (a re-run keeps the run's creation time) are synced again. Runs that didn't change since they were synced
+// are skipped.
+func syncRepoWorkflowRuns(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager, repo *github.Repository) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing workflow runs")
+
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityWorkflowRuns)
+	if err != nil {
+		return err
+	}
+	syncFrom := time.Now().UTC().Add(-syncBackTime)
+	if lastSynced != nil {
+		syncFrom = lo.MinBy([]time.Time{*lastSynced, time.Now().UTC().Add(-workflowRunsLookBack)}, func(a, b time.Time) bool {
+			return a.Before(b)
+		})
+		log.Info("Last synced time found, syncing from latest to the look-back window", "last_synced", lastSynced,
+			"sync_from", syncFrom)
+	}
+
+	// Listing without filters, as filtered listings are capped at 1,000 runs
+	var latestCreatedAt *time.Time
+	opt := &github.ListWorkflowRunsOptions{ListOptions: github.ListOptions{PerPage: workflowRunsPerPage}}
+	for {
+		runs, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.WorkflowRun, *github.Response, error) {
+				runs, resp, err := client.Actions.ListRepositoryWorkflowRuns(ctx, *repo.Owner.Login, *repo.Name, opt)
+				if err != nil {
+					return nil, resp, err
+				}
+				return runs.WorkflowRuns, resp, nil
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list workflow runs")
+		}
+		runsInWindow := lo.Filter(runs, func(run *github.WorkflowRun, _ int) bool {
+			return !run.GetCreatedAt().Before(syncFrom)
+		})
+		syncedUpdatedAt, err := getWorkflowRunsUpdatedAt(ctx, db, repoHost(repo),
+			lo.Map(runsInWindow, func(run *github.WorkflowRun, _ int) int64 { return run.GetID() }),
+		)
+		if err != nil {
+			return err
+		}
+		runsToSync := lo.Filter(runsInWindow, func(run *github.WorkflowRun, _ int) bool {
+			updatedAt, ok := syncedUpdatedAt[run.GetID()]
+			return !ok || !updatedAt.Equal(run.GetUpdatedAt().Time)
+		})
+
+		for _, run := range runsInWindow {
+			createdAt := run.GetCreatedAt().Time
+			if latestCreatedAt == nil || createdAt.After(*latestCreatedAt) {
+				latestCreatedAt = &createdAt
+			}
+		}
+		for _, run := range runsToSync {
+			if err := syncWorkflowJobs(ctx, db, tokenManager, repo, run); err != nil {
+				return errors.Wrap(err, "failed to sync workflow jobs")
+			}
+		}
+		if err := upsertWorkflowRuns(ctx, db, lo.Map(runsToSync, func(run *github.WorkflowRun, _ int) *workflowRun {
+			return newWorkflowRun(repo, run)
+		})); err != nil {
+			return errors.Wrap(err, "failed to insert workflow runs")
+		}
+		log.Info("Synced workflow runs", "runs", len(runsToSync), "unchanged", len(runsInWindow)-len(runsToSync),
+			"page", opt.Page)
+
+		if len(runsInWindow) < len(runs) { // If we filtered, it means we reached the start of the window
+			break
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	if latestCreatedAt != nil {
+		log.Info("Updating last synced time", "last_synced", latestCreatedAt)
+		if err := pg.UpdateLastSyncAt(ctx, db, repoHost(repo), repo.GetFullName(), pg.SyncEntityWorkflowRuns, *latestCreatedAt); err != nil {
+			return errors.Wrap(err, "failed to update last synced time")
+		}
+	}
+
+	return nil
+}
+
+func syncWorkflowJobs(ctx context.Context, db *sqlx.DB, tokenManager *TokenManager,
+	repo *github.Repository, run *github.WorkflowRun,
+) error {
+	// Jobs of all attempts are synced, re-run jobs are how flakiness shows up
+	opt := &github.ListWorkflowJobsOptions{Filter: "all", ListOptions: github.ListOptions{PerPage: workflowJobsPerPage}}
+	for {
+		jobs, resp, err := listEntities(ctx, tokenManager,
+			func(ctx context.Context, client *github.Client) ([]*github.WorkflowJob, *github.Response, error) {
+				jobs, resp, err := client.Actions.ListWorkflowJobs(ctx, *repo.Owner.Login, *repo.Name, run.GetID(), opt)
+				if err != nil {
+					return nil, resp, err
+				}
+				return jobs.Jobs, resp, nil
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to list workflow jobs")
+		}
+		if err := upsertWorkflowJobs(ctx, db, lo.Map(jobs, func(job *github.WorkflowJob, _ int) *workflowJob {
+			return newWorkflowJob(repo, job)
+		})); err != nil {
+			return errors.Wrap(err, "failed to insert workflow jobs")
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return nil
+}
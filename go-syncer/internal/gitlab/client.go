@@ -0,0 +1,91 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/utils/transport"
+)
+
+const perPage = 100
+
+// Client is a minimal client of the GitLab REST API (v4), authenticated with a personal, group or project access token.
+type Client struct {
+	baseURL    *url.URL
+	host       string
+	token      string
+	httpClient *http.Client
+}
+
+func NewClient(source *config.GitLabSource) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(source.BaseURL, "/") + "/api/v4/")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse GitLab base URL")
+	}
+	roundTripper, err := transport.New(source.CABundlePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		baseURL:    baseURL,
+		host:       baseURL.Hostname(),
+		token:      source.Token,
+		httpClient: &http.Client{Transport: roundTripper},
+	}, nil
+}
+
+// get decodes the response of a GET request into out and returns the next page, or 0 on the last page.
+// Rate limited requests are retried once the limit resets.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) (int, error) {
+	log := logging.MustFromContext(ctx)
+
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+
+	log.V(1).Info("Requesting GitLab API", "path", path, "page", query.Get("page"))
+	header, err := transport.GetJSON(ctx, c.httpClient, req, out)
+	if err != nil {
+		return 0, err
+	}
+	nextPage, _ := strconv.Atoi(header.Get("X-Next-Page"))
+	return nextPage, nil
+}
+
+// listAll lists every page of a collection.
+func listAll[T any](ctx context.Context, c *Client, path string, query url.Values) ([]*T, error) {
+	entities := []*T{}
+	query.Set("per_page", strconv.Itoa(perPage))
+	for page := 1; page != 0; {
+		query.Set("page", strconv.Itoa(page))
+		var pageEntities []*T
+		nextPage, err := c.get(ctx, path, query, &pageEntities)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, pageEntities...)
+		page = nextPage
+	}
+	return entities, nil
+}
+
+// projectPath is the API path of a project, projects are addressed by their URL-encoded full path.
+func projectPath(project string, elem ...string) string {
+	return strings.Join(append([]string{"projects", url.PathEscape(project)}, elem...), "/")
+}
+
+func mergeRequestPath(project string, iid int, elem ...string) string {
+	return projectPath(project, append([]string{"merge_requests", fmt.Sprint(iid)}, elem...)...)
+}
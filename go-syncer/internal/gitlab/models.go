@@ -0,0 +1,223 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+)
+
+const (
+	provider = "gitlab"
+
+	reviewStateApproved         = "APPROVED"
+	reviewStateDismissed        = "DISMISSED"
+	reviewStateChangesRequested = "CHANGES_REQUESTED"
+
+	ciStateSuccess = "success"
+	ciStateFailure = "failure"
+	ciStatePending = "pending"
+)
+
+// reviewNotes maps the system notes GitLab adds on review actions to the GitHub review state they're stored as.
+var reviewNotes = map[string]string{
+	"approved this merge request":   reviewStateApproved,
+	"unapproved this merge request": reviewStateDismissed,
+	"requested changes":             reviewStateChangesRequested,
+}
+
+type apiUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type apiProject struct {
+	ID                int64  `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Archived          bool   `json:"archived"`
+}
+
+type apiMergeRequest struct {
+	ID              int64      `json:"id"`
+	IID             int        `json:"iid"`
+	ProjectID       int64      `json:"project_id"`
+	Title           string     `json:"title"`
+	Description     *string    `json:"description"`
+	State           string     `json:"state"`
+	Draft           bool       `json:"draft"`
+	Author          apiUser    `json:"author"`
+	SHA             string     `json:"sha"`
+	MergeCommitSHA  *string    `json:"merge_commit_sha"`
+	SquashCommitSHA *string    `json:"squash_commit_sha"`
+	SourceBranch    string     `json:"source_branch"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	MergedAt        *time.Time `json:"merged_at"`
+
+	// raw is the merge request as returned by the API, stored as is
+	raw json.RawMessage
+}
+
+func (mr *apiMergeRequest) UnmarshalJSON(b []byte) error {
+	type alias apiMergeRequest
+	if err := json.Unmarshal(b, (*alias)(mr)); err != nil {
+		return err //nolint:wrapcheck
+	}
+	mr.raw = append(json.RawMessage{}, b...)
+	return nil
+}
+
+type apiNote struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	Author    apiUser   `json:"author"`
+	System    bool      `json:"system"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	raw json.RawMessage
+}
+
+func (n *apiNote) UnmarshalJSON(b []byte) error {
+	type alias apiNote
+	if err := json.Unmarshal(b, (*alias)(n)); err != nil {
+		return err //nolint:wrapcheck
+	}
+	n.raw = append(json.RawMessage{}, b...)
+	return nil
+}
+
+type apiPipeline struct {
+	ID        int64     `json:"id"`
+	SHA       string    `json:"sha"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type apiCommit struct {
+	ID           string    `json:"id"`
+	AuthoredDate time.Time `json:"authored_date"`
+}
+
+// The pull request rows are shared by every provider.
+type (
+	pullRequest        = pg.PullRequest
+	pullRequestReview  = pg.PullRequestReview
+	pullRequestComment = pg.PullRequestComment
+)
+
+func newPullRequest(host string, project *apiProject, mr *apiMergeRequest) *pullRequest {
+	state := "closed"
+	if mr.State == "opened" {
+		state = "open"
+	}
+	// Squashed merge requests land as the squash commit, which is what deployments and tags contain
+	mergeCommitSHA := mr.MergeCommitSHA
+	if mr.SquashCommitSHA != nil {
+		mergeCommitSHA = mr.SquashCommitSHA
+	}
+	if mr.MergedAt == nil {
+		mergeCommitSHA = nil
+	}
+	return &pullRequest{
+		Host:           host,
+		Provider:       provider,
+		PrID:           mr.ID,
+		RepoID:         &project.ID,
+		Repo:           project.PathWithNamespace,
+		Number:         mr.IID,
+		Username:       mr.Author.Username,
+		Title:          mr.Title,
+		Body:           mr.Description,
+		State:          state,
+		Draft:          mr.Draft,
+		MergedAt:       mr.MergedAt,
+		CreatedAt:      mr.CreatedAt,
+		UpdatedAt:      mr.UpdatedAt,
+		HeadSHA:        mr.SHA,
+		HeadRef:        mr.SourceBranch,
+		MergeCommitSHA: mergeCommitSHA,
+		Data:           mr.raw,
+	}
+}
+
+// newPullRequestReview returns the review a system note records, or nil for notes that aren't review actions.
+func newPullRequestReview(pr *pullRequest, note *apiNote) *pullRequestReview {
+	state, ok := reviewNotes[note.Body]
+	if !note.System || !ok {
+		return nil
+	}
+	return &pullRequestReview{
+		Host:        pr.Host,
+		Provider:    provider,
+		ReviewID:    note.ID,
+		PrID:        pr.PrID,
+		Repo:        pr.Repo,
+		Username:    note.Author.Username,
+		State:       state,
+		SubmittedAt: note.CreatedAt,
+		Data:        note.raw,
+	}
+}
+
+func newPullRequestComment(pr *pullRequest, note *apiNote) *pullRequestComment {
+	return &pullRequestComment{
+		Host:      pr.Host,
+		Provider:  provider,
+		CommentID: note.ID,
+		PrID:      pr.PrID,
+		Repo:      pr.Repo,
+		Username:  note.Author.Username,
+		Body:      note.Body,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+		Data:      note.raw,
+	}
+}
+
+// lastReadyAt returns when a merge request was last marked as ready, from its system notes.
+func lastReadyAt(notes []*apiNote) *time.Time {
+	var readyAt *time.Time
+	for _, note := range notes {
+		if note.System && strings.Contains(note.Body, "as **ready**") {
+			readyAt = lo.ToPtr(note.CreatedAt)
+		}
+	}
+	return readyAt
+}
+
+// setPullRequestCI rolls up the pipelines of the head commit: the latest pipeline decides the state, the first one
+// approximates the time of the push.
+func setPullRequestCI(pr *pullRequest, pipelines []*apiPipeline) {
+	headPipelines := lo.Filter(pipelines, func(p *apiPipeline, _ int) bool { return p.SHA == pr.HeadSHA })
+	if len(headPipelines) == 0 {
+		return
+	}
+	first := lo.MinBy(headPipelines, func(a, b *apiPipeline) bool { return a.CreatedAt.Before(b.CreatedAt) })
+	latest := lo.MaxBy(headPipelines, func(a, b *apiPipeline) bool { return a.ID > b.ID })
+
+	state := ciStatePending
+	switch latest.Status {
+	case "success", "skipped":
+		state = ciStateSuccess
+	case "failed", "canceled":
+		state = ciStateFailure
+	}
+	pr.CIState = &state
+	pr.CIStartedAt = lo.ToPtr(first.CreatedAt)
+	if state != ciStatePending {
+		pr.CICompletedAt = lo.ToPtr(latest.UpdatedAt)
+	}
+}
+
+func firstCommitAt(commits []*apiCommit) *time.Time {
+	if len(commits) == 0 {
+		return nil
+	}
+	first := lo.MinBy(commits, func(a, b *apiCommit) bool { return a.AuthoredDate.Before(b.AuthoredDate) })
+	return lo.ToPtr(first.AuthoredDate)
+}
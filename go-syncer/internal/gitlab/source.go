@@ -0,0 +1,37 @@
+package gitlab
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+)
+
+const sourceName = "gitlab"
+
+// Source syncs the merge requests of the configured GitLab projects and groups.
+type Source struct {
+	config *config.GitLabSource
+	client *Client
+}
+
+func NewSource(source *config.GitLabSource) (*Source, error) {
+	client, err := NewClient(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{config: source, client: client}, nil
+}
+
+func (s *Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Schedule() string {
+	return s.config.Schedule
+}
+
+func (s *Source) Sync(ctx context.Context, db *sqlx.DB) error {
+	return Sync(ctx, db, s.config, s.client)
+}
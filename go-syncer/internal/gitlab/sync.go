@@ -0,0 +1,228 @@
+package gitlab
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/locks"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+const (
+	syncBackTime      = 6 * 30 * 24 * time.Hour
+	mrSyncConcurrency = 3
+)
+
+// Sync syncs the merge requests of the configured projects and group projects into the pull request tables.
+// A project that fails to sync doesn't stop the others, the returned error lists the failures.
+func Sync(ctx context.Context, db *sqlx.DB, source *config.GitLabSource, client *Client) error {
+	log := logging.MustFromContext(ctx).WithValues("source", "gitlab", "host", client.host)
+	ctx = logging.NewContext(ctx, log)
+	log.Info("Syncing projects")
+
+	// Projects that were discovered are synced even when others failed to be discovered
+	projects, failures := discoverProjects(ctx, client, source)
+
+	for _, project := range projects {
+		projectLog := log.WithValues("repo", project.PathWithNamespace)
+		lock, locked, err := locks.TryLock(ctx, db, locks.RepoKey(client.host, project.PathWithNamespace))
+		if err != nil {
+			projectLog.Error(err, "Failed to lock project")
+			failures = append(failures, errors.Wrapf(err, "failed to lock project %s", project.PathWithNamespace).Error())
+			continue
+		}
+		if !locked {
+			projectLog.Info("Project is being synced by another replica, skipping")
+			continue
+		}
+		projectLog.Info("Syncing project")
+		projectCtx, projectRun := syncrun.StartRepo(logging.NewContext(ctx, projectLog), client.host, project.PathWithNamespace)
+		if err := syncProjectMergeRequests(projectCtx, db, client, project); err != nil {
+			projectLog.Error(err, "Failed to sync merge requests")
+			projectRun.Fail(errors.Wrap(err, "failed to sync merge requests"))
+			failures = append(failures, errors.Wrapf(err, "failed to sync merge requests of %s", project.PathWithNamespace).Error())
+		} else {
+			projectLog.Info("Synced project")
+		}
+		projectRun.Finish(projectCtx)
+		lock.Release(projectCtx)
+	}
+
+	log.Info("Synced projects", "failures", len(failures))
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// discoverProjects gets the configured projects and lists the projects of the configured groups. A project or group that
+// fails is logged and returned as a failure, without stopping the others.
+func discoverProjects(ctx context.Context, client *Client, source *config.GitLabSource) ([]*apiProject, []string) {
+	log := logging.MustFromContext(ctx)
+	projects := []*apiProject{}
+	failures := []string{}
+	for _, path := range source.Projects {
+		var project *apiProject
+		if _, err := client.get(ctx, projectPath(path), url.Values{}, &project); err != nil {
+			log.Error(err, "Failed to get project", "repo", path)
+			failures = append(failures, errors.Wrapf(err, "failed to get project %s", path).Error())
+			continue
+		}
+		projects = append(projects, project)
+	}
+	for _, group := range source.Groups {
+		groupProjects, err := listAll[apiProject](ctx, client, "groups/"+url.PathEscape(group)+"/projects", url.Values{
+			"include_subgroups": {"true"},
+			"archived":          {"false"},
+		})
+		if err != nil {
+			log.Error(err, "Failed to list projects of group", "group", group)
+			failures = append(failures, errors.Wrapf(err, "failed to list projects of group %s", group).Error())
+			continue
+		}
+		projects = append(projects, groupProjects...)
+	}
+	return lo.UniqBy(projects, func(p *apiProject) int64 { return p.ID }), failures
+}
+
+// syncProjectMergeRequests syncs the merge requests updated since the last sync, oldest first, moving the watermark
+// after each page.
+func syncProjectMergeRequests(ctx context.Context, db *sqlx.DB, client *Client, project *apiProject) error {
+	log := logging.MustFromContext(ctx)
+
+	lastSynced, err := pg.GetLastSyncAt(ctx, db, client.host, project.PathWithNamespace, pg.SyncEntityPullRequests)
+	if err != nil {
+		return err
+	}
+	updatedAfter := time.Now().UTC().Add(-syncBackTime)
+	if lastSynced != nil {
+		log.Info("Last synced time found, syncing from last sync time", "last_synced", lastSynced)
+		updatedAfter = *lastSynced
+	}
+
+	query := url.Values{
+		"scope":         {"all"},
+		"state":         {"all"},
+		"order_by":      {"updated_at"},
+		"sort":          {"asc"},
+		"updated_after": {updatedAfter.Format(time.RFC3339)},
+		"per_page":      {strconv.Itoa(perPage)},
+	}
+	for page := 1; page != 0; {
+		query.Set("page", strconv.Itoa(page))
+		var mergeRequests []*apiMergeRequest
+		nextPage, err := client.get(ctx, projectPath(project.PathWithNamespace, "merge_requests"), query, &mergeRequests)
+		if err != nil {
+			return errors.Wrap(err, "failed to list merge requests")
+		}
+		if len(mergeRequests) == 0 {
+			break
+		}
+
+		pullRequests, err := syncMergeRequestsChunk(ctx, db, client, project, mergeRequests)
+		if err != nil {
+			return errors.Wrap(err, "failed to sync merge requests chunk")
+		}
+		lastUpdatedAt := pullRequests[len(pullRequests)-1].UpdatedAt
+		log.Info("Synced merge requests", "merge_requests", len(pullRequests), "page", page, "last_mr_updated_at", lastUpdatedAt)
+
+		if err := pg.UpdateLastSyncAt(ctx, db, client.host, project.PathWithNamespace, pg.SyncEntityPullRequests, lastUpdatedAt); err != nil {
+			return errors.Wrap(err, "failed to update last synced time")
+		}
+		page = nextPage
+	}
+	return nil
+}
+
+func syncMergeRequestsChunk(ctx context.Context, db *sqlx.DB, client *Client, project *apiProject,
+	mergeRequests []*apiMergeRequest,
+) ([]*pullRequest, error) {
+	pullRequests := lo.Map(mergeRequests, func(mr *apiMergeRequest, _ int) *pullRequest {
+		return newPullRequest(client.host, project, mr)
+	})
+	reviews := make([][]*pullRequestReview, len(pullRequests))
+	comments := make([][]*pullRequestComment, len(pullRequests))
+
+	sem := make(chan struct{}, mrSyncConcurrency)
+	eg := errgroup.Group{}
+	for i, pr := range pullRequests {
+		i, pr := i, pr
+		sem <- struct{}{}
+		eg.Go(func() error {
+			defer func() { <-sem }()
+			var err error
+			reviews[i], comments[i], err = enrichMergeRequest(ctx, client, pr)
+			return errors.Wrapf(err, "failed to sync merge request %d", pr.Number)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if err := pg.UpsertPullRequests(ctx, db, pullRequests); err != nil {
+		return nil, err
+	}
+	if err := pg.UpsertPullRequestReviews(ctx, db, lo.Flatten(reviews)); err != nil {
+		return nil, err
+	}
+	if err := pg.UpsertPullRequestComments(ctx, db, lo.Flatten(comments)); err != nil {
+		return nil, err
+	}
+	// Notes that were deleted on GitLab are no longer listed
+	for i, pr := range pullRequests {
+		commentIDs := lo.Map(comments[i], func(c *pullRequestComment, _ int) int64 { return c.CommentID })
+		if err := pg.DeletePullRequestCommentsExcept(ctx, db, pr, commentIDs); err != nil {
+			return nil, err
+		}
+	}
+	return pullRequests, nil
+}
+
+// enrichMergeRequest fills in the timeline, first commit and pipeline state of a merge request, and returns its
+// approvals and comments. Approvals are read from system notes, as the approvals API doesn't say when they were given.
+func enrichMergeRequest(ctx context.Context, client *Client, pr *pullRequest,
+) ([]*pullRequestReview, []*pullRequestComment, error) {
+	notes, err := listAll[apiNote](ctx, client, mergeRequestPath(pr.Repo, pr.Number, "notes"), url.Values{
+		"sort":     {"asc"},
+		"order_by": {"created_at"},
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list notes")
+	}
+	reviews := []*pullRequestReview{}
+	comments := []*pullRequestComment{}
+	for _, note := range notes {
+		if review := newPullRequestReview(pr, note); review != nil {
+			reviews = append(reviews, review)
+		}
+		if !note.System && strings.TrimSpace(note.Body) != "" {
+			comments = append(comments, newPullRequestComment(pr, note))
+		}
+	}
+	pr.LastReadyForReviewAt = lastReadyAt(notes)
+
+	commits, err := listAll[apiCommit](ctx, client, mergeRequestPath(pr.Repo, pr.Number, "commits"), url.Values{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list commits")
+	}
+	pr.FirstCommitAt = firstCommitAt(commits)
+
+	pipelines, err := listAll[apiPipeline](ctx, client, mergeRequestPath(pr.Repo, pr.Number, "pipelines"), url.Values{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list pipelines")
+	}
+	setPullRequestCI(pr, pipelines)
+
+	return reviews, comments, nil
+}
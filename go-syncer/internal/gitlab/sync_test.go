@@ -0,0 +1,382 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg"
+	"github.com/ilaif/athena-cycle/syncer/internal/pg/pgtest"
+)
+
+const testProject = "acme/app"
+
+var testTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeGitLab serves a single project of the GitLab API, one entity per page so every listing is paginated.
+type fakeGitLab struct {
+	mergeRequests []map[string]interface{}
+	notes         map[int][]map[string]interface{}
+	commits       map[int][]map[string]interface{}
+	pipelines     map[int][]map[string]interface{}
+	// rateLimited are the paths whose first request is answered with 429
+	rateLimited map[string]bool
+
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+func newFakeGitLab() *fakeGitLab {
+	return &fakeGitLab{
+		notes:       map[int][]map[string]interface{}{},
+		commits:     map[int][]map[string]interface{}{},
+		pipelines:   map[int][]map[string]interface{}{},
+		rateLimited: map[string]bool{},
+	}
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.URL)
+	rateLimited := f.rateLimited[r.URL.EscapedPath()]
+	delete(f.rateLimited, r.URL.EscapedPath())
+	f.mu.Unlock()
+
+	if r.Header.Get("PRIVATE-TOKEN") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rateLimited {
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/"+url.PathEscape(testProject))
+	elems := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	switch {
+	case path == "":
+		writeJSON(w, map[string]interface{}{"id": 7, "path_with_namespace": testProject})
+	case path == "/merge_requests":
+		writePage(w, r, f.mergeRequests)
+	case len(elems) == 3 && elems[0] == "merge_requests":
+		iid, _ := strconv.Atoi(elems[1])
+		switch elems[2] {
+		case "notes":
+			writePage(w, r, f.notes[iid])
+		case "commits":
+			writePage(w, r, f.commits[iid])
+		case "pipelines":
+			writePage(w, r, f.pipelines[iid])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// requestsTo returns the requests made to a path, in order.
+func (f *fakeGitLab) requestsTo(path string) []*url.URL {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := []*url.URL{}
+	for _, u := range f.requests {
+		if u.EscapedPath() == path {
+			requests = append(requests, u)
+		}
+	}
+	return requests
+}
+
+func writePage(w http.ResponseWriter, r *http.Request, entities []map[string]interface{}) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	if page < len(entities) {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	if page > len(entities) {
+		writeJSON(w, []interface{}{})
+		return
+	}
+	writeJSON(w, entities[page-1:page])
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func mergeRequest(iid int, updatedAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            1000 + iid,
+		"iid":           iid,
+		"project_id":    7,
+		"title":         "MR " + strconv.Itoa(iid),
+		"state":         "opened",
+		"author":        map[string]interface{}{"id": 1, "username": "author"},
+		"sha":           "sha" + strconv.Itoa(iid),
+		"source_branch": "branch-" + strconv.Itoa(iid),
+		"created_at":    testTime,
+		"updated_at":    updatedAt,
+	}
+}
+
+func note(id int, username, body string, system bool, createdAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"body":       body,
+		"author":     map[string]interface{}{"id": id, "username": username},
+		"system":     system,
+		"created_at": createdAt,
+		"updated_at": createdAt,
+	}
+}
+
+// syncFake syncs the fake's project and returns the host the rows are stored under.
+func syncFake(t *testing.T, db *sqlx.DB, fake *fakeGitLab) string {
+	t.Helper()
+	host, err := syncFakeProjects(t, db, fake, testProject)
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	return host
+}
+
+// syncFakeProjects syncs projects from the fake, which only serves testProject.
+func syncFakeProjects(t *testing.T, db *sqlx.DB, fake *fakeGitLab, projects ...string) (string, error) {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	source := &config.GitLabSource{BaseURL: server.URL, Token: "token", Projects: projects}
+	client, err := NewClient(source)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	ctx := logging.NewContext(context.Background(), logr.Discard())
+	return client.host, Sync(ctx, db, source, client)
+}
+
+func TestSyncPaginatesMergeRequests(t *testing.T) {
+	db := pgtest.New(t)
+	fake := newFakeGitLab()
+	fake.mergeRequests = []map[string]interface{}{
+		mergeRequest(1, testTime.Add(time.Hour)),
+		mergeRequest(2, testTime.Add(2*time.Hour)),
+		mergeRequest(3, testTime.Add(3*time.Hour)),
+	}
+	fake.commits[2] = []map[string]interface{}{
+		{"id": "c2", "authored_date": testTime.Add(-time.Hour)},
+		{"id": "c1", "authored_date": testTime.Add(-2 * time.Hour)},
+	}
+	fake.pipelines[2] = []map[string]interface{}{
+		{"id": 1, "sha": "sha2", "status": "failed", "created_at": testTime, "updated_at": testTime.Add(time.Minute)},
+		{"id": 2, "sha": "sha2", "status": "success", "created_at": testTime.Add(time.Hour), "updated_at": testTime.Add(2 * time.Hour)},
+	}
+	host := syncFake(t, db, fake)
+
+	if requests := fake.requestsTo("/api/v4/projects/acme%2Fapp/merge_requests"); len(requests) != 3 {
+		t.Errorf("expected 3 merge request pages to be requested, got %d", len(requests))
+	}
+	var numbers []int
+	if err := db.Select(&numbers, `SELECT number FROM pull_requests WHERE provider = 'gitlab' ORDER BY number`); err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != 3 {
+		t.Fatalf("expected 3 pull requests, got %v", numbers)
+	}
+
+	var pr pg.PullRequest
+	if err := db.Get(&pr, `
+		SELECT host, provider, pr_id, repo_id, repo, number, username, title, body, state, draft, additions, deletions,
+			changed_files, merged_at, created_at, updated_at, last_ready_for_review_at, first_commit_at, head_sha, head_ref,
+			ci_state, ci_started_at, ci_completed_at, merge_commit_sha, data
+		FROM pull_requests WHERE number = 2
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Host != host || pr.PrID != 1002 || pr.Repo != testProject || pr.State != "open" {
+		t.Errorf("unexpected pull request: %+v", pr)
+	}
+	if pr.FirstCommitAt == nil || !pr.FirstCommitAt.Equal(testTime.Add(-2*time.Hour)) {
+		t.Errorf("expected first commit at %v, got %v", testTime.Add(-2*time.Hour), pr.FirstCommitAt)
+	}
+	if pr.CIState == nil || *pr.CIState != ciStateSuccess {
+		t.Errorf("expected CI state %q, got %v", ciStateSuccess, pr.CIState)
+	}
+	if pr.CIStartedAt == nil || !pr.CIStartedAt.Equal(testTime) {
+		t.Errorf("expected CI started at %v, got %v", testTime, pr.CIStartedAt)
+	}
+}
+
+func TestSyncRetriesRateLimitedRequests(t *testing.T) {
+	db := pgtest.New(t)
+	fake := newFakeGitLab()
+	fake.mergeRequests = []map[string]interface{}{mergeRequest(1, testTime)}
+	fake.notes[1] = []map[string]interface{}{note(1, "reviewer", "approved this merge request", true, testTime)}
+	notesPath := "/api/v4/projects/acme%2Fapp/merge_requests/1/notes"
+	fake.rateLimited[notesPath] = true
+	syncFake(t, db, fake)
+
+	if requests := fake.requestsTo(notesPath); len(requests) != 2 {
+		t.Errorf("expected the rate limited request to be retried once, got %d requests", len(requests))
+	}
+	var reviews int
+	if err := db.Get(&reviews, `SELECT COUNT(*) FROM pull_request_reviews WHERE provider = 'gitlab'`); err != nil {
+		t.Fatal(err)
+	}
+	if reviews != 1 {
+		t.Errorf("expected the review of the retried request to be synced, got %d reviews", reviews)
+	}
+}
+
+func TestSyncMapsReviewNotes(t *testing.T) {
+	db := pgtest.New(t)
+	fake := newFakeGitLab()
+	fake.mergeRequests = []map[string]interface{}{mergeRequest(1, testTime)}
+	fake.notes[1] = []map[string]interface{}{
+		note(1, "alice", "requested changes", true, testTime.Add(1*time.Minute)),
+		note(2, "alice", "approved this merge request", true, testTime.Add(2*time.Minute)),
+		note(3, "bob", "approved this merge request", true, testTime.Add(3*time.Minute)),
+		note(4, "bob", "unapproved this merge request", true, testTime.Add(4*time.Minute)),
+		note(5, "carol", "approved this merge request", false, testTime.Add(5*time.Minute)),
+		note(6, "carol", "marked this merge request as **ready**", true, testTime.Add(6*time.Minute)),
+		note(7, "carol", "   ", false, testTime.Add(7*time.Minute)),
+	}
+	syncFake(t, db, fake)
+
+	var reviews []struct {
+		ReviewID int64  `db:"review_id"`
+		Username string `db:"username"`
+		State    string `db:"state"`
+	}
+	if err := db.Select(&reviews,
+		`SELECT review_id, username, state FROM pull_request_reviews WHERE pr_id = 1001 ORDER BY review_id`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		id              int64
+		username, state string
+	}{
+		{1, "alice", reviewStateChangesRequested},
+		{2, "alice", reviewStateApproved},
+		{3, "bob", reviewStateApproved},
+		{4, "bob", reviewStateDismissed},
+	}
+	if len(reviews) != len(expected) {
+		t.Fatalf("expected %d reviews, got %+v", len(expected), reviews)
+	}
+	for i, e := range expected {
+		if r := reviews[i]; r.ReviewID != e.id || r.Username != e.username || r.State != e.state {
+			t.Errorf("expected review %d by %s to be %s, got %+v", e.id, e.username, e.state, r)
+		}
+	}
+
+	var comments []int64
+	if err := db.Select(&comments, `SELECT comment_id FROM pull_request_comments WHERE pr_id = 1001`); err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0] != 5 {
+		t.Errorf("expected only the user note to be synced as a comment, got %v", comments)
+	}
+
+	var readyAt *time.Time
+	if err := db.Get(&readyAt, `SELECT last_ready_for_review_at FROM pull_requests WHERE pr_id = 1001`); err != nil {
+		t.Fatal(err)
+	}
+	if readyAt == nil || !readyAt.Equal(testTime.Add(6*time.Minute)) {
+		t.Errorf("expected last ready for review at %v, got %v", testTime.Add(6*time.Minute), readyAt)
+	}
+}
+
+func TestSyncResumesFromWatermark(t *testing.T) {
+	db := pgtest.New(t)
+	fake := newFakeGitLab()
+	fake.mergeRequests = []map[string]interface{}{
+		mergeRequest(1, testTime.Add(time.Hour)),
+		mergeRequest(2, testTime.Add(2*time.Hour)),
+	}
+	host := syncFake(t, db, fake)
+
+	mergeRequestsPath := "/api/v4/projects/acme%2Fapp/merge_requests"
+	requests := fake.requestsTo(mergeRequestsPath)
+	firstUpdatedAfter, err := time.Parse(time.RFC3339, requests[0].Query().Get("updated_after"))
+	if err != nil {
+		t.Fatalf("failed to parse updated_after of the first sync: %v", err)
+	}
+	if since := time.Since(firstUpdatedAfter); since < syncBackTime || since > syncBackTime+time.Minute {
+		t.Errorf("expected the first sync to go back %v, got updated_after %v", syncBackTime, firstUpdatedAfter)
+	}
+
+	lastSynced, err := pg.GetLastSyncAt(context.Background(), db, host, testProject, pg.SyncEntityPullRequests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastSynced == nil || !lastSynced.Equal(testTime.Add(2*time.Hour)) {
+		t.Fatalf("expected the watermark to move to the last merge request, got %v", lastSynced)
+	}
+
+	syncFake(t, db, fake)
+	requests = fake.requestsTo(mergeRequestsPath)
+	if updatedAfter := requests[len(requests)-1].Query().Get("updated_after"); updatedAfter != lastSynced.Format(time.RFC3339) {
+		t.Errorf("expected the next sync to list merge requests updated after %v, got %s", lastSynced, updatedAfter)
+	}
+}
+
+func TestSyncContinuesAfterProjectFailure(t *testing.T) {
+	db := pgtest.New(t)
+	fake := newFakeGitLab()
+	fake.mergeRequests = []map[string]interface{}{mergeRequest(1, testTime)}
+
+	_, err := syncFakeProjects(t, db, fake, "acme/missing", testProject)
+	if err == nil || !strings.Contains(err.Error(), "acme/missing") {
+		t.Errorf("expected the sync to fail with the missing project, got %v", err)
+	}
+	var prs int
+	if err := db.Get(&prs, `SELECT COUNT(*) FROM pull_requests WHERE repo = $1`, testProject); err != nil {
+		t.Fatal(err)
+	}
+	if prs != 1 {
+		t.Errorf("expected the other project to be synced, got %d pull requests", prs)
+	}
+}
+
+func TestSyncDeletesRemovedNotes(t *testing.T) {
+	db := pgtest.New(t)
+	fake := newFakeGitLab()
+	fake.mergeRequests = []map[string]interface{}{mergeRequest(1, testTime)}
+	fake.notes[1] = []map[string]interface{}{
+		note(1, "alice", "first", false, testTime),
+		note(2, "bob", "second", false, testTime.Add(time.Minute)),
+	}
+	syncFake(t, db, fake)
+
+	fake.notes[1] = fake.notes[1][1:]
+	fake.mergeRequests = []map[string]interface{}{mergeRequest(1, testTime.Add(time.Hour))}
+	syncFake(t, db, fake)
+
+	var comments []int64
+	if err := db.Select(&comments, `SELECT comment_id FROM pull_request_comments WHERE pr_id = 1001`); err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0] != 2 {
+		t.Errorf("expected the deleted note to be deleted, got comments %v", comments)
+	}
+}
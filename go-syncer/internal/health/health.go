@@ -0,0 +1,190 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/sources"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+const (
+	cronTimeout = 5 * time.Second
+	pingTimeout = 5 * time.Second
+
+	checkOK = "ok"
+)
+
+// TokenPool is a set of API tokens that may all be rate limited at once, such as the tokens of a GitHub source.
+type TokenPool interface {
+	Exhausted() bool
+}
+
+// Checker serves the liveness and readiness probes of the syncer.
+type Checker struct {
+	db              *sqlx.DB
+	cron            *cron.Cron
+	cronStarted     atomic.Bool
+	registry        *sources.Registry
+	tokenPools      []TokenPool
+	maxSyncAge      time.Duration
+	maxSyncDuration time.Duration
+	startedAt       time.Time
+}
+
+func NewChecker(db *sqlx.DB, c *cron.Cron, registry *sources.Registry, tokenPools []TokenPool, maxSyncAge,
+	maxSyncDuration time.Duration,
+) *Checker {
+	return &Checker{
+		db:              db,
+		cron:            c,
+		registry:        registry,
+		tokenPools:      tokenPools,
+		maxSyncAge:      maxSyncAge,
+		maxSyncDuration: maxSyncDuration,
+		startedAt:       time.Now().UTC(),
+	}
+}
+
+// CronStarted marks the cron as started, until then the syncer is running its initial sync.
+func (c *Checker) CronStarted() {
+	c.cronStarted.Store(true)
+}
+
+// Healthz is alive as long as the process serves requests, the cron responds and no sync is running for longer than
+// the max sync duration. The cron doesn't run before the initial sync is done, so a syncer that's still running it is
+// alive until the sync it's running takes too long.
+func (c *Checker) Healthz(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(ctx, w, map[string]error{
+			"cron":    c.checkCron(),
+			"running": c.checkRunning(),
+		})
+	})
+}
+
+// Readyz is ready when the database is reachable, a token isn't rate limited and every source synced successfully
+// within the max sync age.
+func (c *Checker) Readyz(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(ctx, w, map[string]error{
+			"database": c.checkDatabase(r.Context()),
+			"tokens":   c.checkTokens(),
+			"sync":     c.checkSync(r.Context()),
+		})
+	})
+}
+
+func (c *Checker) checkCron() error {
+	if !c.cronStarted.Load() {
+		return nil
+	}
+	// Entries is answered by the cron's goroutine, so it doesn't return if the cron is stuck
+	entries := make(chan []cron.Entry, 1)
+	go func() { entries <- c.cron.Entries() }()
+	select {
+	case <-entries:
+		return nil
+	case <-time.After(cronTimeout):
+		return errors.New("cron isn't responding")
+	}
+}
+
+// checkRunning fails when a sync has been running for longer than the max sync duration, as a sync that's stuck keeps
+// the cron from starting the next syncs of its source.
+func (c *Checker) checkRunning() error {
+	if c.maxSyncDuration == 0 {
+		return nil
+	}
+	for name, status := range c.registry.Statuses() {
+		if !status.Running || status.LastStartedAt == nil {
+			continue
+		}
+		if duration := time.Since(*status.LastStartedAt); duration > c.maxSyncDuration {
+			return errors.Errorf("source %s has been syncing for %s", name, duration.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+func (c *Checker) checkDatabase(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return errors.Wrap(c.db.PingContext(ctx), "failed to ping database")
+}
+
+func (c *Checker) checkTokens() error {
+	if len(c.tokenPools) == 0 {
+		return nil
+	}
+	for _, pool := range c.tokenPools {
+		if !pool.Exhausted() {
+			return nil
+		}
+	}
+	return errors.New("all tokens are rate limited")
+}
+
+// checkSync fails when a source hasn't synced successfully within the max sync age on top of the interval of its
+// schedule. Sources that didn't sync since the syncer started are measured from its start.
+func (c *Checker) checkSync(ctx context.Context) error {
+	if c.maxSyncAge == 0 {
+		return nil
+	}
+	for _, source := range c.registry.Sources() {
+		succeededAt, err := syncrun.LastSucceededAt(ctx, c.db, source.Name())
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		lastSucceededAt := c.startedAt
+		if succeededAt != nil && succeededAt.After(lastSucceededAt) {
+			lastSucceededAt = *succeededAt
+		}
+		if age := time.Since(lastSucceededAt); age > c.maxSyncAge+scheduleInterval(source.Schedule()) {
+			return errors.Errorf("source %s didn't sync successfully for %s", source.Name(), age.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// scheduleInterval is the time between the next two runs of a schedule.
+func scheduleInterval(spec string) time.Duration {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil { // The schedule was validated when the source was scheduled
+		return 0
+	}
+	next := schedule.Next(time.Now())
+	return schedule.Next(next).Sub(next)
+}
+
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func respond(ctx context.Context, w http.ResponseWriter, checks map[string]error) {
+	resp := response{Status: checkOK, Checks: map[string]string{}}
+	for name, err := range checks {
+		resp.Checks[name] = checkOK
+		if err != nil {
+			resp.Status = "failing"
+			resp.Checks[name] = err.Error()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != checkOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.MustFromContext(ctx).Error(err, "Failed to write health check response")
+	}
+}
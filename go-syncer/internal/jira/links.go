@@ -0,0 +1,121 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+// linkOverlap is how far before the previous run pull requests are matched again.
+const linkOverlap = 5 * time.Minute
+
+// issueKeyPatterns returns the patterns issue keys are matched with, defaulting to the keys of the synced projects.
+// Keys aren't matched inside longer words or numbers, so CDE-12 doesn't match XCDE-12 or CDE-123.
+func issueKeyPatterns(source *config.Jira) []string {
+	if len(source.IssueKeyPatterns) > 0 {
+		return source.IssueKeyPatterns
+	}
+	return lo.Map(source.Projects, func(projectKey string, _ int) string {
+		return fmt.Sprintf(`(?<![A-Za-z0-9])%s-[0-9]+(?![0-9])`, projectKey)
+	})
+}
+
+// LinkPullRequests links pull requests to the Jira issues whose keys appear in their title, body or head branch.
+// The first run, and the first run after the patterns changed, matches every stored pull request, which backfills the
+// links of pull requests synced before linking was set up. Later runs only match the pull requests synced since the
+// previous run, replacing their links so links that were edited out are removed.
+func LinkPullRequests(ctx context.Context, db *sqlx.DB, source *config.Jira) error {
+	log := logging.MustFromContext(ctx)
+	patterns := issueKeyPatterns(source)
+	if len(patterns) == 0 {
+		log.V(1).Info("No Jira issue key patterns, skipping linking pull requests")
+		return nil
+	}
+
+	syncedSince, err := getLastLinkedAt(ctx, db, patterns)
+	if err != nil {
+		return err
+	}
+	if syncedSince != nil {
+		// Pull requests upserted while the previous run was matching may not have been visible to it
+		syncedSince = lo.ToPtr(syncedSince.Add(-linkOverlap))
+		log.Info("Linking pull requests synced since the last run to Jira issues", "since", syncedSince)
+	} else {
+		log.Info("Linking all pull requests to Jira issues", "patterns", patterns)
+	}
+
+	// The run is recorded in the same transaction, so its NOW() is when the pull requests were read
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// Patterns are Postgres regular expressions, matched case-insensitively as branch names are often lowercase.
+	// A pattern with a capture group links the first group, otherwise the whole match.
+	if _, err := tx.ExecContext(ctx, `
+		WITH prs AS (
+			SELECT host, pr_id, repo, title, body, head_ref
+			FROM pull_requests
+			WHERE $2::TIMESTAMP IS NULL OR synced_at >= $2::TIMESTAMP
+		),
+		matches AS (
+			SELECT DISTINCT prs.host, prs.pr_id, prs.repo, UPPER(m[1]) AS issue_key, f.field
+			FROM prs
+			CROSS JOIN LATERAL (VALUES ('title', prs.title), ('body', prs.body), ('head_ref', prs.head_ref)) AS f (field, text)
+			CROSS JOIN UNNEST($1::TEXT[]) AS p (pattern)
+			CROSS JOIN LATERAL REGEXP_MATCHES(f.text, p.pattern, 'gi') AS m
+		),
+		deleted AS (
+			DELETE FROM pull_request_issue_links l
+			WHERE ($2::TIMESTAMP IS NULL OR EXISTS (SELECT 1 FROM prs WHERE prs.host = l.host AND prs.pr_id = l.pr_id))
+				AND NOT EXISTS (
+					SELECT 1 FROM matches
+					WHERE matches.host = l.host AND matches.pr_id = l.pr_id AND matches.issue_key = l.issue_key
+						AND matches.field = l.field
+				)
+		)
+		INSERT INTO pull_request_issue_links (host, pr_id, repo, issue_key, field)
+		SELECT host, pr_id, repo, issue_key, field
+		FROM matches
+		ON CONFLICT (host, pr_id, issue_key, field) DO NOTHING
+	`, patterns, syncedSince); err != nil {
+		return errors.Wrap(err, "failed to link pull requests to jira issues")
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pull_request_issue_link_runs (source, patterns, linked_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (source) DO UPDATE
+		SET patterns = EXCLUDED.patterns,
+			linked_at = EXCLUDED.linked_at
+	`, linkSourceName, patterns); err != nil {
+		return errors.Wrap(err, "failed to record jira link run")
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	log.Info("Linked pull requests to Jira issues")
+	return nil
+}
+
+// getLastLinkedAt returns when pull requests were last linked with the same patterns, or nil if they never were.
+func getLastLinkedAt(ctx context.Context, db *sqlx.DB, patterns []string) (*time.Time, error) {
+	var linkedAt *time.Time
+	err := db.GetContext(ctx, &linkedAt,
+		`SELECT linked_at FROM pull_request_issue_link_runs WHERE source = $1 AND patterns = $2::TEXT[]`,
+		linkSourceName, patterns,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, "failed to get last jira link run")
+	}
+	return linkedAt, nil
+}
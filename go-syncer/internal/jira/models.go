@@ -0,0 +1,109 @@
+package jira
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const sprintField = "customfield_10020"
+
+// issueFields are the fields requested from the search API, everything else is left out to keep pages small.
+var issueFields = []string{
+	"issuetype", "project", "status", "resolution", "resolutiondate", "summary", "created", "updated", "priority",
+	"labels", "assignee", "reporter", sprintField,
+}
+
+type issue struct {
+	ID             int        `db:"id"`
+	Key            string     `db:"key"`
+	IssueType      string     `db:"issue_type"`
+	ProjectKey     string     `db:"project_key"`
+	Status         string     `db:"status"`
+	Resolution     *string    `db:"resolution"`
+	ResolutionDate *time.Time `db:"resolution_date"`
+	Summary        string     `db:"summary"`
+	Created        time.Time  `db:"created"`
+	Updated        time.Time  `db:"updated"`
+	Priority       string     `db:"priority"`
+	Labels         []string   `db:"labels"`
+	AssigneeEmail  *string    `db:"assignee_email"`
+	ReporterEmail  string     `db:"reporter_email"`
+	SprintName     *string    `db:"sprint_name"`
+}
+
+func newIssue(i *jira.Issue) (*issue, error) {
+	id, err := strconv.Atoi(i.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ID of issue %s", i.Key)
+	}
+	fields := i.Fields
+
+	var resolution *string
+	if fields.Resolution != nil {
+		resolution = &fields.Resolution.Name
+	}
+	var resolutionDate *time.Time
+	if t := time.Time(fields.Resolutiondate); !t.IsZero() {
+		resolutionDate = lo.ToPtr(t.UTC())
+	}
+	var priority string
+	if fields.Priority != nil {
+		priority = fields.Priority.Name
+	}
+	var status string
+	if fields.Status != nil {
+		status = fields.Status.Name
+	}
+	var assigneeEmail *string
+	if fields.Assignee != nil {
+		assigneeEmail = &fields.Assignee.EmailAddress
+	}
+	var reporterEmail string
+	if fields.Reporter != nil {
+		reporterEmail = fields.Reporter.EmailAddress
+	}
+	labels := fields.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+
+	return &issue{
+		ID:             id,
+		Key:            i.Key,
+		IssueType:      fields.Type.Name,
+		ProjectKey:     fields.Project.Key,
+		Status:         status,
+		Resolution:     resolution,
+		ResolutionDate: resolutionDate,
+		Summary:        fields.Summary,
+		Created:        time.Time(fields.Created).UTC(),
+		Updated:        time.Time(fields.Updated).UTC(),
+		Priority:       priority,
+		Labels:         labels,
+		AssigneeEmail:  assigneeEmail,
+		ReporterEmail:  reporterEmail,
+		SprintName:     mostRelevantSprint(fields.Unknowns[sprintField]),
+	}, nil
+}
+
+// mostRelevantSprint returns the name of the last sprint of an issue, which is the current one or the one it was
+// completed in.
+func mostRelevantSprint(field interface{}) *string {
+	sprints, ok := field.([]interface{})
+	if !ok || len(sprints) == 0 {
+		return nil
+	}
+	sprint, ok := sprints[len(sprints)-1].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	name, ok := sprint["name"].(string)
+	if !ok {
+		return nil
+	}
+	return &name
+}
@@ -0,0 +1,57 @@
+package jira
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+)
+
+func upsertIssues(ctx context.Context, db *sqlx.DB, issues []*issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO jira_issues (
+				id, key, issue_type, project_key, status, resolution, resolution_date, summary, created, updated, priority, labels,
+				assignee_email, reporter_email, sprint_name
+			)
+			VALUES (
+				:id, :key, :issue_type, :project_key, :status, :resolution, :resolution_date, :summary, :created, :updated, :priority,
+				:labels, :assignee_email, :reporter_email, :sprint_name
+			)
+			ON CONFLICT (id) DO UPDATE
+			SET key = EXCLUDED.key,
+				issue_type = EXCLUDED.issue_type,
+				project_key = EXCLUDED.project_key,
+				status = EXCLUDED.status,
+				resolution = EXCLUDED.resolution,
+				resolution_date = EXCLUDED.resolution_date,
+				summary = EXCLUDED.summary,
+				created = EXCLUDED.created,
+				updated = EXCLUDED.updated,
+				priority = EXCLUDED.priority,
+				labels = EXCLUDED.labels,
+				assignee_email = EXCLUDED.assignee_email,
+				reporter_email = EXCLUDED.reporter_email,
+				sprint_name = EXCLUDED.sprint_name
+			`, issues,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert jira issues")
+	}
+	metrics.AddRowsUpserted("jira_issues", len(issues))
+	return nil
+}
+
+func getLastUpdated(ctx context.Context, db *sqlx.DB, projectKey string) (*time.Time, error) {
+	var lastUpdated *time.Time
+	if err := db.GetContext(ctx, &lastUpdated,
+		`SELECT MAX(updated) FROM jira_issues WHERE project_key = $1`, projectKey,
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to get last updated time of jira issues")
+	}
+	return lastUpdated, nil
+}
@@ -0,0 +1,75 @@
+package jira
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/locks"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+const (
+	sourceName     = "jira"
+	linkSourceName = "jira-links"
+)
+
+// Source syncs the issues of the configured Jira projects.
+type Source struct {
+	config *config.Jira
+	client *Client
+}
+
+func NewSource(source *config.Jira) (*Source, error) {
+	client, err := NewClient(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{config: source, client: client}, nil
+}
+
+func (s *Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Schedule() string {
+	return s.config.Schedule
+}
+
+func (s *Source) Sync(ctx context.Context, db *sqlx.DB) error {
+	return Sync(ctx, db, s.config, s.client)
+}
+
+// LinkSource links pull requests to Jira issues. It's separate from the issues sync since issues may be synced by the
+// Python syncer, and pull requests are synced by the other sources.
+type LinkSource struct {
+	config   *config.Jira
+	schedule string
+}
+
+func NewLinkSource(source *config.Jira, schedule string) *LinkSource {
+	return &LinkSource{config: source, schedule: schedule}
+}
+
+func (s *LinkSource) Name() string {
+	return linkSourceName
+}
+
+func (s *LinkSource) Schedule() string {
+	return s.schedule
+}
+
+// Sync links pull requests on one replica at a time, as every replica would link the same pull requests.
+func (s *LinkSource) Sync(ctx context.Context, db *sqlx.DB) error {
+	lock, locked, err := locks.TryLock(ctx, db, linkSourceName)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		logging.MustFromContext(ctx).Info("Pull requests are being linked by another replica, skipping")
+		return nil
+	}
+	defer lock.Release(ctx)
+	return LinkPullRequests(ctx, db, s.config)
+}
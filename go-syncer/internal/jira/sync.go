@@ -0,0 +1,161 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/config"
+	"github.com/ilaif/athena-cycle/syncer/internal/locks"
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+const (
+	issuesPerPage = 50
+	// jqlTimeLayout is the layout of dates in JQL, which are compared to the minute in the time zone of the user
+	jqlTimeLayout = "2006/01/02 15:04"
+)
+
+// Client is a Jira Cloud client authenticated with the API token of a user.
+type Client struct {
+	*jira.Client
+}
+
+func NewClient(source *config.Jira) (*Client, error) {
+	transport := jira.BasicAuthTransport{Username: source.Username, Password: source.APIToken}
+	client, err := jira.NewClient(transport.Client(), source.SiteURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Jira client")
+	}
+	return &Client{Client: client}, nil
+}
+
+// location returns the time zone of the user, which JQL dates are in.
+func (c *Client) location(ctx context.Context) (*time.Location, error) {
+	user, _, err := c.User.GetSelfWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get jira user")
+	}
+	location, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load time zone %s of jira user", user.TimeZone)
+	}
+	return location, nil
+}
+
+// Sync syncs the issues of the configured projects. A project that fails to sync doesn't stop the others, the
+// returned error lists the failures.
+func Sync(ctx context.Context, db *sqlx.DB, source *config.Jira, client *Client) error {
+	log := logging.MustFromContext(ctx).WithValues("source", "jira")
+	ctx = logging.NewContext(ctx, log)
+	log.Info("Syncing Jira projects")
+
+	location, err := client.location(ctx)
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	for _, projectKey := range source.Projects {
+		projectLog := log.WithValues("project", projectKey)
+		lock, locked, err := locks.TryLock(ctx, db, "jira:"+projectKey)
+		if err != nil {
+			projectLog.Error(err, "Failed to lock Jira project")
+			failures = append(failures, errors.Wrapf(err, "failed to lock jira project %s", projectKey).Error())
+			continue
+		}
+		if !locked {
+			projectLog.Info("Jira project is being synced by another replica, skipping")
+			continue
+		}
+		if err := syncProjectIssues(logging.NewContext(ctx, projectLog), db, source, client, location, projectKey); err != nil {
+			projectLog.Error(err, "Failed to sync Jira issues")
+			failures = append(failures, errors.Wrapf(err, "failed to sync jira issues of %s", projectKey).Error())
+		}
+		lock.Release(ctx)
+	}
+
+	log.Info("Synced Jira projects", "failures", len(failures))
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// syncProjectIssues syncs the issues of a project updated since the latest stored update, oldest first. Each page is
+// stored before the next one is requested, so a sync that fails resumes from its last stored page.
+func syncProjectIssues(ctx context.Context, db *sqlx.DB, source *config.Jira, client *Client, location *time.Location,
+	projectKey string,
+) error {
+	log := logging.MustFromContext(ctx)
+	log.Info("Syncing Jira issues")
+
+	syncFrom, err := getSyncFrom(ctx, db, source, projectKey)
+	if err != nil {
+		return err
+	}
+	log.Info("Syncing Jira issues updated since", "sync_from", syncFrom)
+
+	jql := fmt.Sprintf("project = %q", projectKey)
+	if !syncFrom.IsZero() {
+		jql += fmt.Sprintf(" AND updated >= %q", syncFrom.In(location).Format(jqlTimeLayout))
+	}
+	jql += " ORDER BY updated ASC, key ASC"
+
+	synced := 0
+	opt := &jira.SearchOptions{MaxResults: issuesPerPage, Fields: issueFields}
+	for {
+		jiraIssues, resp, err := client.Issue.SearchWithContext(ctx, jql, opt)
+		if err != nil {
+			return errors.Wrap(err, "failed to search jira issues")
+		}
+		if len(jiraIssues) == 0 {
+			break
+		}
+		opt.StartAt += len(jiraIssues)
+
+		issues := make([]*issue, 0, len(jiraIssues))
+		for i := range jiraIssues {
+			mapped, err := newIssue(&jiraIssues[i])
+			if err != nil {
+				return err
+			}
+			issues = append(issues, mapped)
+		}
+		if err := upsertIssues(ctx, db, issues); err != nil {
+			return err
+		}
+		synced += len(issues)
+		log.V(1).Info("Synced chunk of Jira issues", "issues", len(issues), "start_at", opt.StartAt,
+			"last_updated", issues[len(issues)-1].Updated)
+
+		if opt.StartAt >= resp.Total {
+			break
+		}
+	}
+
+	log.Info("Synced Jira issues", "issues", synced)
+	return nil
+}
+
+// getSyncFrom returns the update time to sync issues from: the latest stored update of the project, JIRA_SYNC_FROM on
+// the first sync, or JIRA_FORCE_RESYNC_FROM when set. Unlike the Python job, which syncs from the earlier of
+// JIRA_SYNC_FROM and the latest update on every run, JIRA_SYNC_FROM only bounds the first sync.
+func getSyncFrom(ctx context.Context, db *sqlx.DB, source *config.Jira, projectKey string) (time.Time, error) {
+	if !source.ForceResyncFrom.IsZero() {
+		return source.ForceResyncFrom.Time, nil
+	}
+	lastUpdated, err := getLastUpdated(ctx, db, projectKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lastUpdated != nil {
+		return *lastUpdated, nil
+	}
+	return source.SyncFrom.Time, nil
+}
@@ -0,0 +1,289 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+
+	pollInterval        = 5 * time.Second
+	heartbeatInterval   = time.Minute
+	visibilityTimeout   = 10 * time.Minute
+	maintenanceInterval = time.Minute
+	succeededRetention  = 7 * 24 * time.Hour
+	baseBackoff         = time.Minute
+	maxBackoff          = 6 * time.Hour
+)
+
+const (
+	outcomeInterrupted = "interrupted"
+	outcomeDeferred    = "deferred"
+	outcomeLost        = "lost"
+)
+
+var errClaimLost = errors.New("job is no longer claimed by this worker")
+
+// Job is a unit of sync work in the jobs table.
+type Job struct {
+	ID          int64           `db:"id"`
+	Kind        string          `db:"kind"`
+	Payload     json.RawMessage `db:"payload"`
+	Attempts    int             `db:"attempts"`
+	MaxAttempts int             `db:"max_attempts"`
+	// ClaimID identifies the claim of the job by a worker, the outcome of the job is recorded for that claim only
+	ClaimID string `db:"claim_id"`
+}
+
+func (j *Job) Decode(payload interface{}) error {
+	return errors.Wrapf(json.Unmarshal(j.Payload, payload), "failed to decode payload of job %d", j.ID)
+}
+
+// Handler runs a job, a job that returns an error is retried. The context is canceled when the worker shuts down or
+// loses the claim on the job.
+type Handler func(ctx context.Context, job *Job) error
+
+// RetryLater is returned by a handler to run the job again after delay without counting the attempt, for a job that
+// couldn't start, e.g. because its repository is being synced by another job.
+func RetryLater(delay time.Duration, reason string) error {
+	return &retryLaterError{delay: delay, reason: reason}
+}
+
+type retryLaterError struct {
+	delay  time.Duration
+	reason string
+}
+
+func (e *retryLaterError) Error() string {
+	return e.reason
+}
+
+// Queue is a durable queue of jobs in Postgres.
+type Queue struct {
+	db *sqlx.DB
+}
+
+func NewQueue(db *sqlx.DB) *Queue {
+	return &Queue{db: db}
+}
+
+// Enqueue queues a job to run as soon as a worker is free, unless a job with the same dedup key is already queued or
+// running.
+func (q *Queue) Enqueue(ctx context.Context, kind, dedupKey string, payload interface{}) error {
+	_, err := enqueue(ctx, q.db, kind, dedupKey, payload)
+	return err
+}
+
+// EnqueueTx queues a job like Enqueue within a transaction, to record what waits for the job along with it. It returns
+// the ID of the queued job, or of the job with the same dedup key that was already queued or running.
+func (q *Queue) EnqueueTx(ctx context.Context, tx *sqlx.Tx, kind, dedupKey string, payload interface{}) (int64, error) {
+	return enqueue(ctx, tx, kind, dedupKey, payload)
+}
+
+func enqueue(ctx context.Context, db sqlx.QueryerContext, kind, dedupKey string, payload interface{}) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to encode payload of %s job", kind)
+	}
+	return insertJob(ctx, db, kind, dedupKey, data)
+}
+
+// Worker runs the jobs of the kinds it handles. Workers of all replicas share the queue, each job is claimed by one of
+// them with SELECT ... FOR UPDATE SKIP LOCKED. A claimed job is kept alive by a heartbeat, the jobs of a worker that
+// stops sending it, e.g. because its pod was killed, are queued again.
+type Worker struct {
+	queue       *Queue
+	id          string
+	concurrency int
+	handlers    map[string]Handler
+}
+
+func NewWorker(queue *Queue, concurrency int) *Worker {
+	hostname, _ := os.Hostname()
+	return &Worker{
+		queue:       queue,
+		id:          fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		concurrency: concurrency,
+		handlers:    map[string]Handler{},
+	}
+}
+
+func (w *Worker) Handle(kind string, handler Handler) {
+	w.handlers[kind] = handler
+}
+
+// Run runs jobs until the context is done, then waits for the running jobs to stop.
+func (w *Worker) Run(ctx context.Context) {
+	log := logging.MustFromContext(ctx).WithValues("worker", w.id)
+	ctx = logging.NewContext(ctx, log)
+	if len(w.handlers) == 0 || w.concurrency == 0 {
+		log.Info("No job handlers or workers, not running jobs")
+		return
+	}
+	log.Info("Running jobs", "concurrency", w.concurrency, "kinds", lo.Keys(w.handlers))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.runJobs(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.maintain(ctx)
+	}()
+	wg.Wait()
+	log.Info("Stopped running jobs")
+}
+
+func (w *Worker) runJobs(ctx context.Context) {
+	log := logging.MustFromContext(ctx)
+	for ctx.Err() == nil {
+		job, err := claimJob(ctx, w.queue.db, w.id, lo.Keys(w.handlers))
+		if err != nil && ctx.Err() == nil {
+			log.Error(err, "Failed to claim job")
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		w.runJob(ctx, job)
+	}
+}
+
+func (w *Worker) runJob(ctx context.Context, job *Job) {
+	log := logging.MustFromContext(ctx).WithValues("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	ctx = logging.NewContext(ctx, log)
+	log.Info("Running job")
+
+	// The heartbeat cancels the job when it finds the claim lost, so it doesn't keep running alongside a retry
+	jobCtx, cancelJob := context.WithCancelCause(ctx)
+	defer cancelJob(nil)
+	heartbeatCtx, stopHeartbeat := context.WithCancel(jobCtx)
+	go w.heartbeat(heartbeatCtx, job, cancelJob)
+	startedAt := time.Now()
+	err := w.handlers[job.Kind](jobCtx, job)
+	stopHeartbeat()
+
+	// The outcome is recorded even when the job was interrupted by a shutdown
+	recordCtx := context.WithoutCancel(ctx)
+	var outcome string
+	var retryLater *retryLaterError
+	switch {
+	case errors.Is(context.Cause(jobCtx), errClaimLost):
+		err = errClaimLost
+	case err == nil:
+		outcome = StatusSucceeded
+		err = succeedJob(recordCtx, w.queue.db, job)
+	case ctx.Err() != nil:
+		outcome = outcomeInterrupted
+		log.Info("Job was interrupted, queueing it again")
+		err = requeueJob(recordCtx, w.queue.db, job, 0)
+	case errors.As(err, &retryLater):
+		outcome = outcomeDeferred
+		log.Info("Job can't run yet, queueing it again", "reason", retryLater.reason, "delay", retryLater.delay)
+		err = requeueJob(recordCtx, w.queue.db, job, retryLater.delay)
+	default:
+		log.Error(err, "Job failed")
+		backoff := backoffOf(job.Attempts)
+		outcome, err = failJob(recordCtx, w.queue.db, job, err.Error(), backoff)
+		switch outcome {
+		case StatusQueued:
+			log.Info("Retrying job", "backoff", backoff)
+		case StatusDead:
+			log.Info("Job ran out of attempts, it's dead")
+		}
+	}
+	switch {
+	case errors.Is(err, errClaimLost):
+		outcome = outcomeLost
+		log.Info("Job is no longer claimed by this worker, not recording its outcome")
+	case err != nil:
+		log.Error(err, "Failed to record job outcome")
+	}
+	metrics.JobsProcessed.WithLabelValues(job.Kind, outcome).Inc()
+	log.Info("Ran job", "outcome", outcome, "duration", time.Since(startedAt))
+}
+
+// heartbeat keeps the claim on a running job, and cancels the job with errClaimLost when the claim was lost.
+func (w *Worker) heartbeat(ctx context.Context, job *Job, cancelJob context.CancelCauseFunc) {
+	log := logging.MustFromContext(ctx)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stillClaimed, err := heartbeatJob(ctx, w.queue.db, job)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error(err, "Failed to send job heartbeat")
+				}
+				continue
+			}
+			if !stillClaimed {
+				log.Info("Job is no longer claimed by this worker, stopping it")
+				cancelJob(errClaimLost)
+				return
+			}
+		}
+	}
+}
+
+// maintain queues the jobs of workers that stopped responding again, deletes old succeeded jobs and reports the size
+// of the queue.
+func (w *Worker) maintain(ctx context.Context) {
+	log := logging.MustFromContext(ctx)
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for {
+		reaped, err := reapJobs(ctx, w.queue.db, visibilityTimeout)
+		if err != nil && ctx.Err() == nil {
+			log.Error(err, "Failed to reap jobs")
+		}
+		if reaped > 0 {
+			log.Info("Queued jobs of unresponsive workers again", "jobs", reaped)
+		}
+		if err := deleteSucceededJobs(ctx, w.queue.db, succeededRetention); err != nil && ctx.Err() == nil {
+			log.Error(err, "Failed to delete succeeded jobs")
+		}
+		if err := reportQueue(ctx, w.queue.db); err != nil && ctx.Err() == nil {
+			log.Error(err, "Failed to report job queue")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backoffOf is the delay before retrying a job that failed its attempt, doubling from a minute up to 6 hours.
+func backoffOf(attempts int) time.Duration {
+	backoff := float64(baseBackoff) * math.Pow(2, float64(attempts-1)) //nolint:gomnd
+	return time.Duration(math.Min(backoff, float64(maxBackoff)))
+}
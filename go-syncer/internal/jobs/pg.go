@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+)
+
+// insertJob inserts a queued job and returns its ID. A job with the same dedup key that's already queued or running is
+// kept, and its ID is returned instead.
+func insertJob(ctx context.Context, db sqlx.QueryerContext, kind, dedupKey string, payload json.RawMessage,
+) (int64, error) {
+	var id int64
+	if err := sqlx.GetContext(ctx, db, &id, `
+		INSERT INTO jobs (kind, dedup_key, payload)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dedup_key) WHERE status IN ('queued', 'running') DO UPDATE SET dedup_key = EXCLUDED.dedup_key
+		RETURNING id
+	`, kind, dedupKey, payload); err != nil {
+		return 0, errors.Wrapf(err, "failed to enqueue %s job", kind)
+	}
+	return id, nil
+}
+
+// claimJob claims the next due job of the given kinds, skipping the jobs other workers are claiming.
+func claimJob(ctx context.Context, db *sqlx.DB, workerID string, kinds []string) (*Job, error) {
+	var job Job
+	err := db.GetContext(ctx, &job, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, claim_id = gen_random_uuid(), locked_by = $1, locked_at = NOW(),
+			updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued' AND run_at <= NOW() AND kind = ANY($2)
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, attempts, max_attempts, claim_id::TEXT AS claim_id
+	`, workerID, kinds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil //nolint:nilnil // No job is due
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim job")
+	}
+	return &job, nil
+}
+
+// The outcome of a job is only recorded by the claim that ran it. A worker whose job was reaped, e.g. because its
+// heartbeats didn't get through, gets errClaimLost instead of overwriting the outcome of the job's next attempt, even
+// when the next attempt was claimed by another goroutine of the same worker.
+
+// heartbeatJob keeps a claimed job from being reaped, and returns whether the claim still holds.
+func heartbeatJob(ctx context.Context, db *sqlx.DB, job *Job) (bool, error) {
+	res, err := db.ExecContext(ctx, `
+		UPDATE jobs SET locked_at = NOW() WHERE id = $1 AND claim_id = $2 AND status = 'running'
+	`, job.ID, job.ClaimID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update job heartbeat")
+	}
+	updated, _ := res.RowsAffected()
+	return updated > 0, nil
+}
+
+func succeedJob(ctx context.Context, db *sqlx.DB, job *Job) error {
+	res, err := db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'succeeded', locked_by = NULL, locked_at = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND claim_id = $2 AND status = 'running'
+	`, job.ID, job.ClaimID)
+	if err != nil {
+		return errors.Wrap(err, "failed to mark job as succeeded")
+	}
+	return claimed(res)
+}
+
+// requeueJob queues a job to run again after delay, without counting the attempt, e.g. when it was interrupted.
+func requeueJob(ctx context.Context, db *sqlx.DB, job *Job, delay time.Duration) error {
+	res, err := db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'queued', attempts = attempts - 1, run_at = NOW() + $3 * INTERVAL '1 second', locked_by = NULL,
+			locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND claim_id = $2 AND status = 'running'
+	`, job.ID, job.ClaimID, delay.Seconds())
+	if err != nil {
+		return errors.Wrap(err, "failed to queue job again")
+	}
+	return claimed(res)
+}
+
+// failJob queues a failed job to be retried after the backoff, or dead-letters it when it ran out of attempts.
+// Dead jobs stay in the table with their last error until they're deleted or queued again by hand.
+func failJob(ctx context.Context, db *sqlx.DB, job *Job, message string, backoff time.Duration,
+) (string, error) {
+	var status string
+	err := db.GetContext(ctx, &status, `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+			run_at = NOW() + $4 * INTERVAL '1 second',
+			finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+			last_error = $3, locked_by = NULL, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND claim_id = $2 AND status = 'running'
+		RETURNING status
+	`, job.ID, job.ClaimID, message, backoff.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errClaimLost
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to mark job as failed")
+	}
+	return status, nil
+}
+
+func claimed(res sql.Result) error {
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return errClaimLost
+	}
+	return nil
+}
+
+// reapJobs queues the running jobs whose worker didn't send a heartbeat within the visibility timeout again.
+func reapJobs(ctx context.Context, db *sqlx.DB, timeout time.Duration) (int64, error) {
+	res, err := db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+			run_at = NOW(),
+			finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+			last_error = 'worker ' || locked_by || ' stopped responding',
+			locked_by = NULL, locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < NOW() - $1 * INTERVAL '1 second'
+	`, timeout.Seconds())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to reap jobs")
+	}
+	reaped, _ := res.RowsAffected()
+	return reaped, nil
+}
+
+func deleteSucceededJobs(ctx context.Context, db *sqlx.DB, retention time.Duration) error {
+	if _, err := db.ExecContext(ctx, `
+		DELETE FROM jobs WHERE status = 'succeeded' AND finished_at < NOW() - $1 * INTERVAL '1 second'
+	`, retention.Seconds()); err != nil {
+		return errors.Wrap(err, "failed to delete succeeded jobs")
+	}
+	return nil
+}
+
+func reportQueue(ctx context.Context, db *sqlx.DB) error {
+	rows := []struct {
+		Kind   string `db:"kind"`
+		Status string `db:"status"`
+		Jobs   int    `db:"jobs"`
+	}{}
+	if err := db.SelectContext(ctx, &rows, `SELECT kind, status, jobs FROM job_queue`); err != nil {
+		return errors.Wrap(err, "failed to get job queue")
+	}
+	metrics.Jobs.Reset()
+	for _, row := range rows {
+		metrics.Jobs.WithLabelValues(row.Kind, row.Status).Set(float64(row.Jobs))
+	}
+	return nil
+}
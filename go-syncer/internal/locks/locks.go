@@ -0,0 +1,56 @@
+package locks
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+)
+
+// Lock is a Postgres session-level advisory lock, held on a connection taken out of the pool until it's released.
+// When a replica dies, its connections close and Postgres releases its locks, so another replica takes over the work
+// on its next run.
+type Lock struct {
+	key  string
+	conn *sqlx.Conn
+}
+
+// TryLock takes the lock of a key without waiting, locked is false when another replica holds it.
+func TryLock(ctx context.Context, db *sqlx.DB, key string) (lock *Lock, locked bool, err error) {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get a database connection")
+	}
+	if err := conn.GetContext(ctx, &locked, `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`, key); err != nil {
+		conn.Close()
+		return nil, false, errors.Wrapf(err, "failed to lock %s", key)
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+	return &Lock{key: key, conn: conn}, true, nil
+}
+
+// Release releases the lock and returns its connection to the pool. A connection that fails to release the lock is
+// discarded instead, which releases it as well.
+func (l *Lock) Release(ctx context.Context) {
+	// The lock is released even when the sync was interrupted
+	ctx = context.WithoutCancel(ctx)
+	defer l.conn.Close()
+
+	var unlocked bool
+	if err := l.conn.GetContext(ctx, &unlocked, `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, l.key); err == nil && unlocked {
+		return
+	}
+	logging.MustFromContext(ctx).Info("Failed to release lock, discarding its connection", "key", l.key)
+	_ = l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+}
+
+// RepoKey is the key of the lock on syncing a repository.
+func RepoKey(host, repo string) string {
+	return "repo:" + host + "/" + repo
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "syncer"
+
+var (
+	APICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_calls_total",
+		Help:      "API calls by host, templated endpoint and response status.",
+	}, []string{"host", "endpoint", "status"})
+
+	RateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_remaining",
+		Help:      "Requests remaining in the rate limit window of a token, by host and token.",
+	}, []string{"host", "token"})
+
+	TokenRotations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_rotations_total",
+		Help:      "Rotations to the next token after a rate limited token.",
+	}, []string{"host"})
+
+	BackoffSleeps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backoff_sleeps_total",
+		Help:      "Waits for a rate limit to reset.",
+	}, []string{"host"})
+
+	BackoffSleepSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backoff_sleep_seconds_total",
+		Help:      "Time spent waiting for rate limits to reset.",
+	}, []string{"host"})
+
+	RowsUpserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_upserted_total",
+		Help:      "Rows upserted by table.",
+	}, []string{"table"})
+
+	RepoSyncDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repo_sync_duration_seconds",
+		Help:      "Duration of the sync of a repository.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14), //nolint:gomnd // 1s to ~2h
+	}, []string{"host", "repo", "status"})
+
+	RepoLastSuccessfulSync = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "repo_last_successful_sync_timestamp_seconds",
+		Help:      "Unix time of the end of the last successful sync of a repository.",
+	}, []string{"host", "repo"})
+
+	JobsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_processed_total",
+		Help:      "Attempts of queued jobs by kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	Jobs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs",
+		Help:      "Queued, running and dead jobs by kind and status.",
+	}, []string{"kind", "status"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func AddRowsUpserted(table string, rows int) {
+	RowsUpserted.WithLabelValues(table).Add(float64(rows))
+}
+
+// endpointTemplates replace the parts of API paths that name repositories, projects, users and compared refs, in the
+// order they're applied. Bitbucket Server's /projects/P/repos/r goes first, so it isn't mistaken for GitHub's
+// /repos/owner/repo. A compared range ends the path and may contain slashes from branch names.
+var endpointTemplates = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`/projects/[^/]+/repos/[^/]+`), "/projects/:project/repos/:repo"},
+	{regexp.MustCompile(`/repos/[^/:][^/]*/[^/]+`), "/repos/:owner/:repo"},
+	{regexp.MustCompile(`/repositories/[^/]+/[^/]+`), "/repositories/:workspace/:repo"},
+	{regexp.MustCompile(`/(projects|groups|orgs|users)/[^/:][^/]*`), "/$1/:name"},
+	{regexp.MustCompile(`/compare/.+$`), "/compare/:range"},
+	{regexp.MustCompile(`/[0-9a-f]{40}(/|$)`), "/:sha$1"},
+	{regexp.MustCompile(`/[0-9]+(/|$)`), "/:id$1"},
+}
+
+// Endpoint templates the path of an API request to bound the cardinality of the endpoint label,
+// e.g. /repos/acme/app/pulls/12/reviews becomes /repos/:owner/:repo/pulls/:id/reviews.
+func Endpoint(path string) string {
+	for _, t := range endpointTemplates {
+		path = t.pattern.ReplaceAllString(path, t.replacement)
+	}
+	// Consecutive IDs overlap their separators, so a second pass templates every other one
+	path = endpointTemplates[len(endpointTemplates)-1].pattern.ReplaceAllString(path, "/:id$1")
+	return strings.TrimSuffix(path, "/")
+}
@@ -0,0 +1,78 @@
+// Package pgtest provides a migrated Postgres database to tests.
+package pgtest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// URLEnv is the environment variable holding the URL of the Postgres database tests run against.
+const URLEnv = "TEST_PG_URL"
+
+// New returns a connection to a fresh schema of the test database with the migrations applied, and drops the schema
+// once the test is done. Tests are skipped when no test database is configured.
+func New(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	pgURL := os.Getenv(URLEnv)
+	if pgURL == "" {
+		t.Skipf("%s isn't set", URLEnv)
+	}
+
+	admin, err := sqlx.Connect("pgx", pgURL)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema: %v", err)
+		}
+		admin.Close()
+	})
+
+	u, err := url.Parse(pgURL)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", URLEnv, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	migrator, err := migrate.New("file://"+migrationsDir(), u.String())
+	if err != nil {
+		t.Fatalf("failed to create migrate instance: %v", err)
+	}
+	defer migrator.Close()
+	if err := migrator.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	db, err := sqlx.Connect("pgx", u.String())
+	if err != nil {
+		t.Fatalf("failed to connect to test schema: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// migrationsDir is the migrations directory at the root of the module.
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "migrations")
+}
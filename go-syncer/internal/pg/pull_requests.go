@@ -0,0 +1,182 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+// PullRequest is a row of pull_requests, which holds the pull requests of every provider. Columns a provider doesn't
+// have, such as the change stats of Bitbucket pull requests, are left null. synced_at is set on every upsert.
+type PullRequest struct {
+	Host                 string      `db:"host"`
+	Provider             string      `db:"provider"`
+	PrID                 int64       `db:"pr_id"`
+	RepoID               *int64      `db:"repo_id"`
+	Repo                 string      `db:"repo"`
+	Number               int         `db:"number"`
+	Username             string      `db:"username"`
+	Title                string      `db:"title"`
+	Body                 *string     `db:"body"`
+	State                string      `db:"state"`
+	Draft                bool        `db:"draft"`
+	Additions            *int        `db:"additions"`
+	Deletions            *int        `db:"deletions"`
+	ChangedFiles         *int        `db:"changed_files"`
+	MergedAt             *time.Time  `db:"merged_at"`
+	CreatedAt            time.Time   `db:"created_at"`
+	UpdatedAt            time.Time   `db:"updated_at"`
+	LastReadyForReviewAt *time.Time  `db:"last_ready_for_review_at"`
+	FirstCommitAt        *time.Time  `db:"first_commit_at"`
+	HeadSHA              string      `db:"head_sha"`
+	HeadRef              string      `db:"head_ref"`
+	CIState              *string     `db:"ci_state"`
+	CIStartedAt          *time.Time  `db:"ci_started_at"`
+	CICompletedAt        *time.Time  `db:"ci_completed_at"`
+	MergeCommitSHA       *string     `db:"merge_commit_sha"`
+	Data                 interface{} `db:"data"`
+}
+
+// PullRequestReview is a row of pull_request_reviews, or an approval for providers without reviews.
+type PullRequestReview struct {
+	Host        string      `db:"host"`
+	Provider    string      `db:"provider"`
+	ReviewID    int64       `db:"review_id"`
+	PrID        int64       `db:"pr_id"`
+	Repo        string      `db:"repo"`
+	Username    string      `db:"username"`
+	State       string      `db:"state"`
+	SubmittedAt time.Time   `db:"submitted_at"`
+	CommitID    string      `db:"commit_id"`
+	Data        interface{} `db:"data"`
+}
+
+// PullRequestComment is a row of pull_request_comments, the conversation comments of a pull request.
+type PullRequestComment struct {
+	Host      string      `db:"host"`
+	Provider  string      `db:"provider"`
+	CommentID int64       `db:"comment_id"`
+	PrID      int64       `db:"pr_id"`
+	Repo      string      `db:"repo"`
+	Username  string      `db:"username"`
+	Body      string      `db:"body"`
+	CreatedAt time.Time   `db:"created_at"`
+	UpdatedAt time.Time   `db:"updated_at"`
+	Data      interface{} `db:"data"`
+}
+
+func UpsertPullRequests(ctx context.Context, db *sqlx.DB, pullRequests []*PullRequest) error {
+	if len(pullRequests) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_requests (
+				host, provider, pr_id, repo, repo_id, number, username, title, body, state, draft, additions, deletions,
+				changed_files, merged_at, created_at, updated_at, last_ready_for_review_at, first_commit_at, head_sha,
+				head_ref, ci_state, ci_started_at, ci_completed_at, merge_commit_sha, data
+			)
+			VALUES (
+				:host, :provider, :pr_id, :repo, :repo_id, :number, :username, :title, :body, :state, :draft, :additions, :deletions,
+				:changed_files, :merged_at, :created_at, :updated_at, :last_ready_for_review_at, :first_commit_at, :head_sha,
+				:head_ref, :ci_state, :ci_started_at, :ci_completed_at, :merge_commit_sha, :data
+			)
+			ON CONFLICT (host, pr_id) DO UPDATE
+			SET provider = EXCLUDED.provider,
+				repo = EXCLUDED.repo,
+				repo_id = EXCLUDED.repo_id,
+				number = EXCLUDED.number,
+				username = EXCLUDED.username,
+				title = EXCLUDED.title,
+				body = EXCLUDED.body,
+				state = EXCLUDED.state,
+				draft = EXCLUDED.draft,
+				additions = EXCLUDED.additions,
+				deletions = EXCLUDED.deletions,
+				changed_files = EXCLUDED.changed_files,
+				merged_at = EXCLUDED.merged_at,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				last_ready_for_review_at = EXCLUDED.last_ready_for_review_at,
+				first_commit_at = EXCLUDED.first_commit_at,
+				head_sha = EXCLUDED.head_sha,
+				head_ref = EXCLUDED.head_ref,
+				ci_state = EXCLUDED.ci_state,
+				ci_started_at = EXCLUDED.ci_started_at,
+				ci_completed_at = EXCLUDED.ci_completed_at,
+				merge_commit_sha = EXCLUDED.merge_commit_sha,
+				data = EXCLUDED.data,
+				synced_at = NOW()
+			`, pullRequests,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull requests")
+	}
+	syncrun.AddPullRequests(ctx, len(pullRequests))
+	metrics.AddRowsUpserted("pull_requests", len(pullRequests))
+	return nil
+}
+
+func UpsertPullRequestReviews(ctx context.Context, db *sqlx.DB, reviews []*PullRequestReview) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_request_reviews (host, provider, review_id, pr_id, repo, username, state, submitted_at, commit_id, data)
+			VALUES (:host, :provider, :review_id, :pr_id, :repo, :username, :state, :submitted_at, :commit_id, :data)
+			ON CONFLICT (host, review_id) DO UPDATE
+			SET provider = EXCLUDED.provider,
+				pr_id = EXCLUDED.pr_id,
+				repo = EXCLUDED.repo,
+				username = EXCLUDED.username,
+				state = EXCLUDED.state,
+				submitted_at = EXCLUDED.submitted_at,
+				commit_id = EXCLUDED.commit_id,
+				data = EXCLUDED.data
+			`, reviews,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull request reviews")
+	}
+	syncrun.AddReviews(ctx, len(reviews))
+	metrics.AddRowsUpserted("pull_request_reviews", len(reviews))
+	return nil
+}
+
+func UpsertPullRequestComments(ctx context.Context, db *sqlx.DB, comments []*PullRequestComment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, `
+			INSERT INTO pull_request_comments (host, provider, comment_id, pr_id, repo, username, body, created_at, updated_at, data)
+			VALUES (:host, :provider, :comment_id, :pr_id, :repo, :username, :body, :created_at, :updated_at, :data)
+			ON CONFLICT (host, comment_id) DO UPDATE
+			SET provider = EXCLUDED.provider,
+				pr_id = EXCLUDED.pr_id,
+				repo = EXCLUDED.repo,
+				username = EXCLUDED.username,
+				body = EXCLUDED.body,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				data = EXCLUDED.data
+			`, comments,
+	); err != nil {
+		return errors.Wrap(err, "failed to insert pull request comments")
+	}
+	metrics.AddRowsUpserted("pull_request_comments", len(comments))
+	return nil
+}
+
+// DeletePullRequestCommentsExcept deletes the stored comments of a pull request that aren't among commentIDs, the
+// comments it has now.
+func DeletePullRequestCommentsExcept(ctx context.Context, db *sqlx.DB, pr *PullRequest, commentIDs []int64) error {
+	if _, err := db.ExecContext(ctx,
+		`DELETE FROM pull_request_comments WHERE host = $1 AND pr_id = $2 AND comment_id <> ALL($3)`,
+		pr.Host, pr.PrID, commentIDs,
+	); err != nil {
+		return errors.Wrap(err, "failed to delete deleted pull request comments")
+	}
+	return nil
+}
@@ -9,23 +9,47 @@ import (
 	"github.com/pkg/errors"
 )
 
-func GetLastSyncAt(ctx context.Context, db *sqlx.DB, repo string) (*time.Time, error) {
+// SyncEntity is what a sync watermark is tracked for, each entity of a repository is synced incrementally on its own.
+type SyncEntity string
+
+const (
+	SyncEntityPullRequests SyncEntity = "pull_requests"
+	SyncEntityWorkflowRuns SyncEntity = "workflow_runs"
+	SyncEntityDeployments  SyncEntity = "deployments"
+	SyncEntityIssues       SyncEntity = "issues"
+	// SyncEntityIssueLinks is when the pull requests of a repository were last linked to the issues they close
+	SyncEntityIssueLinks SyncEntity = "issue_links"
+)
+
+func GetLastSyncAt(ctx context.Context, db *sqlx.DB, host, repo string, entity SyncEntity) (*time.Time, error) {
 	var lastSynced *time.Time
-	err := db.GetContext(ctx, &lastSynced, `SELECT last_synced FROM sync_status WHERE repo = $1`, repo)
+	err := db.GetContext(ctx, &lastSynced,
+		`SELECT last_synced FROM sync_status WHERE host = $1 AND repo = $2 AND entity = $3`, host, repo, entity,
+	)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.Wrap(err, "failed to get last synced time")
 	}
 	return lastSynced, nil
 }
 
-func UpdateLastSyncAt(ctx context.Context, db *sqlx.DB, repo string, lastSynced time.Time) error {
+func UpdateLastSyncAt(ctx context.Context, db *sqlx.DB, host, repo string, entity SyncEntity, lastSynced time.Time) error {
 	if _, err := db.ExecContext(ctx, `
-		INSERT INTO sync_status (repo, last_synced)
-		VALUES ($1, $2)
-		ON CONFLICT (repo) DO UPDATE
+		INSERT INTO sync_status (host, repo, entity, last_synced)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (host, repo, entity) DO UPDATE
 		SET last_synced = EXCLUDED.last_synced
-	`, repo, lastSynced); err != nil {
+	`, host, repo, entity, lastSynced); err != nil {
 		return errors.Wrap(err, "failed to update last synced time")
 	}
 	return nil
 }
+
+// DeleteLastSyncAt clears a watermark, so the next sync of the entity starts from the beginning of the sync window.
+func DeleteLastSyncAt(ctx context.Context, db *sqlx.DB, host, repo string, entity SyncEntity) error {
+	if _, err := db.ExecContext(ctx,
+		`DELETE FROM sync_status WHERE host = $1 AND repo = $2 AND entity = $3`, host, repo, entity,
+	); err != nil {
+		return errors.Wrap(err, "failed to delete last synced time")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package sources
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+// Source is a system the syncer syncs from, such as a GitHub instance or a Jira site.
+type Source interface {
+	// Name identifies the source in logs and statuses, it must be unique.
+	Name() string
+	// Schedule is the cron spec the source is synced on.
+	Schedule() string
+	Sync(ctx context.Context, db *sqlx.DB) error
+}
+
+// Status is the outcome of the latest syncs of a source.
+type Status struct {
+	Running         bool
+	LastStartedAt   *time.Time
+	LastFinishedAt  *time.Time
+	LastSucceededAt *time.Time
+	LastError       string
+}
+
+// Registry holds the enabled sources and tracks the status of their syncs.
+// Sources are synced independently, a failing source doesn't stop the others from syncing.
+type Registry struct {
+	db       *sqlx.DB
+	sources  []Source
+	mu       sync.Mutex
+	statuses map[string]*Status
+}
+
+func NewRegistry(db *sqlx.DB) *Registry {
+	return &Registry{db: db, statuses: map[string]*Status{}}
+}
+
+func (r *Registry) Register(source Source) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.statuses[source.Name()]; ok {
+		return errors.Errorf("source %s is already registered", source.Name())
+	}
+	r.sources = append(r.sources, source)
+	r.statuses[source.Name()] = &Status{}
+	return nil
+}
+
+func (r *Registry) Sources() []Source {
+	return r.sources
+}
+
+// SyncAll syncs every source once, in the order they were registered.
+func (r *Registry) SyncAll(ctx context.Context) {
+	for _, source := range r.sources {
+		if ctx.Err() != nil {
+			return
+		}
+		_ = r.Sync(ctx, source)
+	}
+}
+
+// Sync syncs a source and records the outcome in its status. Errors are logged, and returned for callers that care.
+func (r *Registry) Sync(ctx context.Context, source Source) error {
+	log := logging.MustFromContext(ctx).WithValues("source", source.Name())
+
+	startedAt := time.Now().UTC()
+	r.updateStatus(source, func(s *Status) {
+		s.Running = true
+		s.LastStartedAt = &startedAt
+	})
+	log.Info("Syncing source")
+
+	ctx, run := syncrun.Start(ctx, r.db, source.Name())
+	err := source.Sync(ctx, r.db)
+	run.Finish(ctx, err)
+
+	finishedAt := time.Now().UTC()
+	r.updateStatus(source, func(s *Status) {
+		s.Running = false
+		s.LastFinishedAt = &finishedAt
+		s.LastError = ""
+		if err != nil {
+			s.LastError = err.Error()
+		} else {
+			s.LastSucceededAt = &finishedAt
+		}
+	})
+	if err != nil {
+		log.Error(err, "Failed to sync source", "duration", finishedAt.Sub(startedAt))
+		return errors.Wrapf(err, "failed to sync source %s", source.Name())
+	}
+	log.Info("Synced source", "duration", finishedAt.Sub(startedAt))
+	return nil
+}
+
+// Schedule adds a job per source to the cron. A source's sync is skipped while its previous sync is still running.
+func (r *Registry) Schedule(ctx context.Context, c *cron.Cron) error {
+	log := logging.MustFromContext(ctx)
+	for _, source := range r.sources {
+		source := source
+		job := cron.NewChain(cron.SkipIfStillRunning(log.WithValues("source", source.Name()))).Then(cron.FuncJob(func() {
+			_ = r.Sync(ctx, source)
+		}))
+		if _, err := c.AddJob(source.Schedule(), job); err != nil {
+			return errors.Wrapf(err, "failed to schedule source %s", source.Name())
+		}
+		log.Info("Scheduled source", "source", source.Name(), "schedule", source.Schedule())
+	}
+	return nil
+}
+
+// Statuses returns a copy of the status of every source by name.
+func (r *Registry) Statuses() map[string]Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make(map[string]Status, len(r.statuses))
+	for name, s := range r.statuses {
+		statuses[name] = *s
+	}
+	return statuses
+}
+
+func (r *Registry) updateStatus(source Source, update func(s *Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(r.statuses[source.Name()])
+}
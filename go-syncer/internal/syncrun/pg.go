@@ -0,0 +1,131 @@
+package syncrun
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+func insertRun(ctx context.Context, db *sqlx.DB, r *Run) (int64, error) {
+	var id int64
+	if err := db.GetContext(ctx, &id, `
+		INSERT INTO sync_runs (source, status, started_at)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, r.source, StatusRunning, r.startedAt); err != nil {
+		return 0, errors.Wrap(err, "failed to insert sync run")
+	}
+	return id, nil
+}
+
+// finishRun records the outcome of a run. The counters are added to the row's, which the jobs attached to the run
+// add their counters to as well. A waiting run has no finish time until it's finalized.
+func finishRun(ctx context.Context, db *sqlx.DB, r *Run, status, message string) error {
+	var finishedAt *time.Time
+	if status != StatusWaiting {
+		finishedAt = lo.ToPtr(time.Now().UTC())
+	}
+	if _, err := db.ExecContext(ctx, `
+		UPDATE sync_runs
+		SET status = $2, finished_at = $3, prs_upserted = prs_upserted + $4, reviews_upserted = reviews_upserted + $5,
+			api_calls = api_calls + $6, error = NULLIF($7, '')
+		WHERE id = $1
+	`, r.id, status, finishedAt, r.prsUpserted.Load(), r.reviewsUpserted.Load(), r.apiCalls.Load(), message,
+	); err != nil {
+		return errors.Wrap(err, "failed to update sync run")
+	}
+	return nil
+}
+
+// addRunCounters adds the counters of a job attached to a run to the run's.
+func addRunCounters(ctx context.Context, db *sqlx.DB, r *Run) error {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE sync_runs
+		SET prs_upserted = prs_upserted + $2, reviews_upserted = reviews_upserted + $3, api_calls = api_calls + $4
+		WHERE id = $1
+	`, r.id, r.prsUpserted.Load(), r.reviewsUpserted.Load(), r.apiCalls.Load()); err != nil {
+		return errors.Wrap(err, "failed to update sync run")
+	}
+	return nil
+}
+
+func insertRunJob(ctx context.Context, tx *sqlx.Tx, runID, jobID int64) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO sync_run_jobs (run_id, job_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, runID, jobID); err != nil {
+		return errors.Wrap(err, "failed to record job of sync run")
+	}
+	return nil
+}
+
+func getJobRunID(ctx context.Context, db *sqlx.DB, jobID int64) (int64, error) {
+	var runID *int64
+	if err := db.GetContext(ctx, &runID, `SELECT MAX(run_id) FROM sync_run_jobs WHERE job_id = $1`, jobID); err != nil {
+		return 0, errors.Wrap(err, "failed to get sync run of job")
+	}
+	return lo.FromPtr(runID), nil
+}
+
+// finalizeRuns finishes the waiting runs of a source whose jobs are done. A run fails when the source failed or one of
+// its jobs is dead, a job that succeeded after retries doesn't fail it. The run finishes with its last repository.
+func finalizeRuns(ctx context.Context, db *sqlx.DB, source string) error {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE sync_runs r
+		SET status = CASE
+				WHEN r.error IS NOT NULL
+					OR EXISTS (
+						SELECT 1 FROM sync_run_jobs rj JOIN jobs j ON j.id = rj.job_id
+						WHERE rj.run_id = r.id AND j.status = 'dead'
+					)
+				THEN 'failed'
+				ELSE 'succeeded'
+			END,
+			finished_at = COALESCE((SELECT MAX(rr.finished_at) FROM sync_run_repos rr WHERE rr.run_id = r.id), $2)
+		WHERE r.source = $1 AND r.status = 'waiting' AND NOT EXISTS (
+			SELECT 1 FROM sync_run_jobs rj JOIN jobs j ON j.id = rj.job_id
+			WHERE rj.run_id = r.id AND j.status IN ('queued', 'running')
+		)
+	`, source, time.Now().UTC()); err != nil {
+		return errors.Wrap(err, "failed to finalize sync runs")
+	}
+	return nil
+}
+
+func getLastSucceededAt(ctx context.Context, db *sqlx.DB, source string) (*time.Time, error) {
+	var lastSucceededAt *time.Time
+	if err := db.GetContext(ctx, &lastSucceededAt, `
+		SELECT MAX(finished_at) FROM sync_runs WHERE source = $1 AND status = 'succeeded'
+	`, source); err != nil {
+		return nil, errors.Wrap(err, "failed to get last successful sync run")
+	}
+	return lastSucceededAt, nil
+}
+
+func insertRepo(ctx context.Context, db *sqlx.DB, r *Repo) (int64, error) {
+	var id int64
+	if err := db.GetContext(ctx, &id, `
+		INSERT INTO sync_run_repos (run_id, host, repo, status, started_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, r.run.id, r.host, r.repo, StatusRunning, r.startedAt); err != nil {
+		return 0, errors.Wrap(err, "failed to insert sync run repository")
+	}
+	return id, nil
+}
+
+func finishRepo(ctx context.Context, db *sqlx.DB, r *Repo, status, message string) error {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE sync_run_repos
+		SET status = $2, finished_at = $3, prs_upserted = $4, reviews_upserted = $5, api_calls = $6, error = NULLIF($7, '')
+		WHERE id = $1
+	`, r.id, status, time.Now().UTC(), r.prsUpserted.Load(), r.reviewsUpserted.Load(), r.apiCalls.Load(), message,
+	); err != nil {
+		return errors.Wrap(err, "failed to update sync run repository")
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package syncrun
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	// StatusWaiting is a run whose source finished queueing jobs, it's finalized once the jobs are done
+	StatusWaiting = "waiting"
+)
+
+type runKey struct{}
+
+type repoKey struct{}
+
+// counters are what a run or a repository's sync spent and upserted.
+type counters struct {
+	prsUpserted     atomic.Int64
+	reviewsUpserted atomic.Int64
+	apiCalls        atomic.Int64
+}
+
+// Run is a sync of a source, recorded in sync_runs. Recording is best effort, a run that fails to be recorded is
+// logged and doesn't fail the sync.
+//
+// A source may queue its repositories as jobs, which are recorded in sync_run_jobs. Such a run waits for its jobs once
+// the source is done, and the next run of the source finalizes it from the outcomes of the jobs.
+type Run struct {
+	counters
+	db        *sqlx.DB
+	id        int64
+	source    string
+	startedAt time.Time
+	// attached is a job's handle on the run of the source that queued it
+	attached    bool
+	waitForJobs atomic.Bool
+}
+
+// Repo is the sync of a repository within a run, recorded in sync_run_repos.
+type Repo struct {
+	counters
+	run       *Run
+	id        int64
+	host      string
+	repo      string
+	startedAt time.Time
+	mu        sync.Mutex
+	errs      []string
+}
+
+// Start records the start of a sync of a source, and returns a context the run's repositories are started from.
+// The previous runs of the source that were waiting for jobs are finalized if their jobs are done.
+func Start(ctx context.Context, db *sqlx.DB, source string) (context.Context, *Run) {
+	log := logging.MustFromContext(ctx)
+	if err := finalizeRuns(ctx, db, source); err != nil {
+		log.Error(err, "Failed to finalize sync runs", "source", source)
+	}
+	r := &Run{db: db, source: source, startedAt: time.Now().UTC()}
+	id, err := insertRun(ctx, db, r)
+	if err != nil {
+		log.Error(err, "Failed to record sync run", "source", source)
+	}
+	r.id = id
+	return context.WithValue(ctx, runKey{}, r), r
+}
+
+// Attach returns a context whose repositories are recorded in the run with the given ID, for a job queued by the run.
+// Finishing it only adds its counters to the run, the run's outcome is decided by the outcomes of its jobs.
+func Attach(ctx context.Context, db *sqlx.DB, source string, id int64) (context.Context, *Run) {
+	r := &Run{db: db, id: id, source: source, startedAt: time.Now().UTC(), attached: true}
+	return context.WithValue(ctx, runKey{}, r), r
+}
+
+// AddJob records that the run of the context waits for a job, within the transaction that queued it. A job that a
+// previous run queued already is waited for by both runs.
+func AddJob(ctx context.Context, tx *sqlx.Tx, jobID int64) error {
+	run, ok := ctx.Value(runKey{}).(*Run)
+	if !ok || run.id == 0 {
+		return nil
+	}
+	return insertRunJob(ctx, tx, run.id, jobID)
+}
+
+// JobRunID returns the ID of the latest run waiting for a job, which the job records its repository in, or 0 when no
+// run waits for it, e.g. for a job queued by hand.
+func JobRunID(ctx context.Context, db *sqlx.DB, jobID int64) (int64, error) {
+	return getJobRunID(ctx, db, jobID)
+}
+
+// WaitForJobs makes the run of the context wait for the jobs it queued once it's finished, instead of succeeding.
+func WaitForJobs(ctx context.Context) {
+	if run, ok := ctx.Value(runKey{}).(*Run); ok {
+		run.waitForJobs.Store(true)
+	}
+}
+
+// Finish records the end of the run, which failed if err isn't nil. A run waiting for its jobs keeps the error, which
+// fails it once it's finalized.
+func (r *Run) Finish(ctx context.Context, err error) {
+	if r.id == 0 {
+		return
+	}
+	// The run is recorded even when it was interrupted by a shutdown
+	ctx = context.WithoutCancel(ctx)
+	log := logging.MustFromContext(ctx)
+	if r.attached {
+		if err := addRunCounters(ctx, r.db, r); err != nil {
+			log.Error(err, "Failed to record sync run", "source", r.source)
+		}
+		return
+	}
+
+	status, message := StatusSucceeded, ""
+	if err != nil {
+		status, message = StatusFailed, err.Error()
+	}
+	if r.waitForJobs.Load() {
+		status = StatusWaiting
+	}
+	if err := finishRun(ctx, r.db, r, status, message); err != nil {
+		log.Error(err, "Failed to record sync run", "source", r.source)
+		return
+	}
+	if status == StatusWaiting {
+		// The jobs may be done already, e.g. when every repository was queued by a previous run
+		if err := finalizeRuns(ctx, r.db, r.source); err != nil {
+			log.Error(err, "Failed to finalize sync runs", "source", r.source)
+		}
+	}
+}
+
+// LastSucceededAt returns when the last successful run of a source finished, or nil if none did. Runs waiting for
+// their jobs only count once they're finalized.
+func LastSucceededAt(ctx context.Context, db *sqlx.DB, source string) (*time.Time, error) {
+	return getLastSucceededAt(ctx, db, source)
+}
+
+// StartRepo records the start of a repository's sync within the run of the context, and returns a context that
+// counts the API calls and upserts of the repository. Outside a run, such as when handling webhooks, nothing is
+// recorded.
+func StartRepo(ctx context.Context, host, repo string) (context.Context, *Repo) {
+	r := &Repo{host: host, repo: repo, startedAt: time.Now().UTC()}
+	if run, ok := ctx.Value(runKey{}).(*Run); ok && run.id != 0 {
+		r.run = run
+		id, err := insertRepo(ctx, run.db, r)
+		if err != nil {
+			logging.MustFromContext(ctx).Error(err, "Failed to record sync of repository")
+		}
+		r.id = id
+	}
+	return context.WithValue(ctx, repoKey{}, r), r
+}
+
+// Fail records an error of the repository's sync. The sync of a repository may go on after an error, e.g. to sync
+// workflow runs after failing to sync pull requests, so every error is recorded.
+func (r *Repo) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err.Error())
+}
+
+// Err returns the errors recorded for the repository's sync, or nil if it didn't fail.
+func (r *Repo) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(r.errs, "; "))
+}
+
+// Finish records the end of the repository's sync, which failed if any error was recorded.
+func (r *Repo) Finish(ctx context.Context) {
+	r.mu.Lock()
+	status, message := StatusSucceeded, strings.Join(r.errs, "; ")
+	if len(r.errs) > 0 {
+		status = StatusFailed
+	}
+	r.mu.Unlock()
+
+	metrics.RepoSyncDuration.WithLabelValues(r.host, r.repo, status).Observe(time.Since(r.startedAt).Seconds())
+	if status == StatusSucceeded {
+		metrics.RepoLastSuccessfulSync.WithLabelValues(r.host, r.repo).SetToCurrentTime()
+	}
+	if r.id == 0 {
+		return
+	}
+	if err := finishRepo(context.WithoutCancel(ctx), r.run.db, r, status, message); err != nil {
+		logging.MustFromContext(ctx).Error(err, "Failed to record sync of repository")
+	}
+}
+
+func AddPullRequests(ctx context.Context, n int) {
+	add(ctx, func(c *counters) { c.prsUpserted.Add(int64(n)) })
+}
+
+func AddReviews(ctx context.Context, n int) {
+	add(ctx, func(c *counters) { c.reviewsUpserted.Add(int64(n)) })
+}
+
+func AddAPICall(ctx context.Context) {
+	add(ctx, func(c *counters) { c.apiCalls.Add(1) })
+}
+
+// add updates the counters of the repository and run of the context.
+func add(ctx context.Context, update func(c *counters)) {
+	if repo, ok := ctx.Value(repoKey{}).(*Repo); ok {
+		update(&repo.counters)
+	}
+	if run, ok := ctx.Value(runKey{}).(*Run); ok {
+		update(&run.counters)
+	}
+}
@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/logging"
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+)
+
+// defaultRateLimitDelay is how long to wait on a rate limited response that doesn't say when to retry.
+const defaultRateLimitDelay = time.Minute
+
+// GetJSON sends a bodiless request and decodes its JSON response into out, waiting out rate limited responses and
+// retrying them. It returns the headers of the response for the caller to read pagination from.
+func GetJSON(ctx context.Context, client *http.Client, req *http.Request, out interface{}) (http.Header, error) {
+	log := logging.MustFromContext(ctx)
+
+	for {
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to request %s", req.URL.Path)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			delay := rateLimitDelay(resp.Header)
+			log.Info("Rate limit reached, waiting", "host", req.URL.Host, "delay", delay)
+			metrics.BackoffSleeps.WithLabelValues(req.URL.Host).Inc()
+			metrics.BackoffSleepSeconds.WithLabelValues(req.URL.Host).Add(delay.Seconds())
+			select {
+			case <-ctx.Done():
+				return nil, errors.Wrap(ctx.Err(), "context done while waiting for rate limit reset")
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("failed to request %s: %s", req.URL.Path, resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode response of %s", req.URL.Path)
+		}
+		return resp.Header, nil
+	}
+}
+
+// rateLimitDelay reads when to retry a rate limited response from Retry-After, or from GitLab's RateLimit-Reset.
+func rateLimitDelay(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(header.Get("RateLimit-Reset"), 10, 64); err == nil {
+		if delay := time.Until(time.Unix(reset, 0)); delay > 0 {
+			return delay
+		}
+	}
+	return defaultRateLimitDelay
+}
@@ -0,0 +1,91 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/ilaif/athena-cycle/syncer/internal/metrics"
+	"github.com/ilaif/athena-cycle/syncer/internal/syncrun"
+)
+
+// New returns the HTTP transport to reach a self-hosted instance with, trusting the CA bundle on top of the system
+// certificates when one is set. Requests are counted as API calls of the sync run of their context and in the metrics.
+func New(caBundlePath string) (http.RoundTripper, error) {
+	if caBundlePath == "" {
+		return &instrumented{base: http.DefaultTransport}, nil
+	}
+	caBundle, err := os.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA bundle")
+	}
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load system cert pool")
+	}
+	if !rootCAs.AppendCertsFromPEM(caBundle) {
+		return nil, errors.Errorf("no certificates found in CA bundle %s", caBundlePath)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	return &instrumented{base: transport}, nil
+}
+
+// rateLimitRemainingHeaders are the headers GitHub, GitLab and Bitbucket report the remaining rate limit in.
+var rateLimitRemainingHeaders = []string{"X-RateLimit-Remaining", "RateLimit-Remaining"}
+
+type instrumented struct {
+	base http.RoundTripper
+}
+
+func (t *instrumented) RoundTrip(req *http.Request) (*http.Response, error) {
+	syncrun.AddAPICall(req.Context())
+	// The escaped path keeps GitLab's URL-encoded project paths in a single segment
+	endpoint := metrics.Endpoint(req.URL.EscapedPath())
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		metrics.APICalls.WithLabelValues(req.URL.Host, endpoint, "error").Inc()
+		return nil, err //nolint:wrapcheck
+	}
+	metrics.APICalls.WithLabelValues(req.URL.Host, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	for _, header := range rateLimitRemainingHeaders {
+		if remaining, err := strconv.Atoi(resp.Header.Get(header)); err == nil {
+			metrics.RateLimitRemaining.WithLabelValues(req.URL.Host, tokenLabel(req)).Set(float64(remaining))
+			break
+		}
+	}
+	return resp, nil
+}
+
+type tokenLabelKey struct{}
+
+// defaultTokenLabel labels the requests of clients with a single token, such as the GitLab and Bitbucket clients.
+const defaultTokenLabel = "default"
+
+// WithTokenLabel labels the requests sent through base with a stable name of the token they're authenticated with,
+// e.g. the index of a personal access token, as the rate limit metrics are reported per token.
+func WithTokenLabel(base http.RoundTripper, label string) http.RoundTripper {
+	return &tokenLabeled{base: base, label: label}
+}
+
+type tokenLabeled struct {
+	base  http.RoundTripper
+	label string
+}
+
+func (t *tokenLabeled) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(context.WithValue(req.Context(), tokenLabelKey{}, t.label))) //nolint:wrapcheck
+}
+
+func tokenLabel(req *http.Request) string {
+	if label, ok := req.Context().Value(tokenLabelKey{}).(string); ok {
+		return label
+	}
+	return defaultTokenLabel
+}
@@ -0,0 +1,2 @@
+-- 000004_create_repositories.down.sql
+DROP TABLE IF EXISTS repositories;
@@ -0,0 +1,14 @@
+-- 000004_create_repositories.up.sql
+CREATE TABLE
+  repositories (
+    id SERIAL PRIMARY KEY,
+    repo_id INT8 UNIQUE,
+    repo TEXT,
+    owner TEXT,
+    name TEXT,
+    archived BOOLEAN,
+    fork BOOLEAN,
+    first_discovered_at TIMESTAMP,
+    last_discovered_at TIMESTAMP,
+    data JSON
+  );
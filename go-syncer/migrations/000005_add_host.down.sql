@@ -0,0 +1,20 @@
+-- 000005_add_host.down.sql
+ALTER TABLE sync_status
+DROP CONSTRAINT sync_status_pkey,
+DROP COLUMN host,
+ADD PRIMARY KEY (repo);
+
+ALTER TABLE repositories
+DROP CONSTRAINT repositories_host_repo_id_key,
+DROP COLUMN host,
+ADD CONSTRAINT repositories_repo_id_key UNIQUE (repo_id);
+
+ALTER TABLE pull_request_reviews
+DROP CONSTRAINT pull_request_reviews_host_review_id_key,
+DROP COLUMN host,
+ADD CONSTRAINT pull_request_reviews_review_id_key UNIQUE (review_id);
+
+ALTER TABLE pull_requests
+DROP CONSTRAINT pull_requests_host_pr_id_key,
+DROP COLUMN host,
+ADD CONSTRAINT pull_requests_pr_id_key UNIQUE (pr_id);
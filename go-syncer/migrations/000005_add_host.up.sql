@@ -0,0 +1,20 @@
+-- 000005_add_host.up.sql
+ALTER TABLE pull_requests
+ADD COLUMN host TEXT NOT NULL DEFAULT 'github.com',
+DROP CONSTRAINT pull_requests_pr_id_key,
+ADD CONSTRAINT pull_requests_host_pr_id_key UNIQUE (host, pr_id);
+
+ALTER TABLE pull_request_reviews
+ADD COLUMN host TEXT NOT NULL DEFAULT 'github.com',
+DROP CONSTRAINT pull_request_reviews_review_id_key,
+ADD CONSTRAINT pull_request_reviews_host_review_id_key UNIQUE (host, review_id);
+
+ALTER TABLE repositories
+ADD COLUMN host TEXT NOT NULL DEFAULT 'github.com',
+DROP CONSTRAINT repositories_repo_id_key,
+ADD CONSTRAINT repositories_host_repo_id_key UNIQUE (host, repo_id);
+
+ALTER TABLE sync_status
+ADD COLUMN host TEXT NOT NULL DEFAULT 'github.com',
+DROP CONSTRAINT sync_status_pkey,
+ADD PRIMARY KEY (host, repo);
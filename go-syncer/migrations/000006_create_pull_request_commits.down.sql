@@ -0,0 +1,5 @@
+-- 000006_create_pull_request_commits.down.sql
+ALTER TABLE pull_requests
+DROP COLUMN first_commit_at;
+
+DROP TABLE IF EXISTS pull_request_commits;
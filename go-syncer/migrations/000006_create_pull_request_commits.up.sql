@@ -0,0 +1,21 @@
+-- 000006_create_pull_request_commits.up.sql
+CREATE TABLE
+  pull_request_commits (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    pr_id INT8,
+    repo TEXT,
+    sha TEXT,
+    author_login TEXT,
+    author_email TEXT,
+    committer_login TEXT,
+    committer_email TEXT,
+    authored_at TIMESTAMP,
+    committed_at TIMESTAMP,
+    message_headline TEXT,
+    data JSON,
+    UNIQUE (host, pr_id, sha)
+  );
+
+ALTER TABLE pull_requests
+ADD COLUMN first_commit_at TIMESTAMP;
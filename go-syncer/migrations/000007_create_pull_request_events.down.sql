@@ -0,0 +1,2 @@
+-- 000007_create_pull_request_events.down.sql
+DROP TABLE IF EXISTS pull_request_events;
@@ -0,0 +1,20 @@
+-- 000007_create_pull_request_events.up.sql
+CREATE TABLE
+  pull_request_events (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    event_id INT8,
+    pr_id INT8,
+    repo TEXT,
+    event TEXT,
+    actor TEXT,
+    created_at TIMESTAMP,
+    requested_reviewer TEXT,
+    requested_team TEXT,
+    label TEXT,
+    assignee TEXT,
+    commit_id TEXT,
+    data JSON
+  );
+
+CREATE INDEX pull_request_events_host_pr_id_idx ON pull_request_events (host, pr_id);
@@ -0,0 +1,4 @@
+-- 000008_create_pull_request_comments.down.sql
+DROP TABLE IF EXISTS pull_request_comments;
+
+DROP TABLE IF EXISTS pull_request_review_comments;
@@ -0,0 +1,39 @@
+-- 000008_create_pull_request_comments.up.sql
+CREATE TABLE
+  pull_request_review_comments (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    comment_id INT8,
+    pr_id INT8,
+    review_id INT8,
+    repo TEXT,
+    username TEXT,
+    path TEXT,
+    line INT,
+    original_line INT,
+    start_line INT,
+    side TEXT,
+    in_reply_to INT8,
+    diff_hunk TEXT,
+    commit_id TEXT,
+    body TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, comment_id)
+  );
+
+CREATE TABLE
+  pull_request_comments (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    comment_id INT8,
+    pr_id INT8,
+    repo TEXT,
+    username TEXT,
+    body TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, comment_id)
+  );
@@ -0,0 +1,2 @@
+-- 000009_create_pull_request_files.down.sql
+DROP TABLE IF EXISTS pull_request_files;
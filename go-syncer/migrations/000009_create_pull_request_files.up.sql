@@ -0,0 +1,16 @@
+-- 000009_create_pull_request_files.up.sql
+CREATE TABLE
+  pull_request_files (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    pr_id INT8,
+    repo TEXT,
+    filename TEXT,
+    status TEXT,
+    additions INT,
+    deletions INT,
+    changes INT,
+    previous_filename TEXT,
+    sha TEXT,
+    UNIQUE (host, pr_id, filename)
+  );
@@ -0,0 +1,2 @@
+-- 000010_create_pull_request_cycle_times.down.sql
+DROP TABLE IF EXISTS pull_request_cycle_times;
@@ -0,0 +1,22 @@
+-- 000010_create_pull_request_cycle_times.up.sql
+CREATE TABLE
+  pull_request_cycle_times (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    pr_id INT8,
+    repo TEXT,
+    number INT,
+    username TEXT,
+    first_commit_at TIMESTAMP,
+    ready_for_review_at TIMESTAMP,
+    first_review_at TIMESTAMP,
+    approved_at TIMESTAMP,
+    merged_at TIMESTAMP,
+    coding_time_seconds INT8,
+    pickup_time_seconds INT8,
+    review_time_seconds INT8,
+    merge_time_seconds INT8,
+    cycle_time_seconds INT8,
+    computed_at TIMESTAMP,
+    UNIQUE (host, pr_id)
+  );
@@ -0,0 +1,12 @@
+-- 000011_create_workflow_runs.down.sql
+DROP TABLE IF EXISTS workflow_jobs;
+
+DROP TABLE IF EXISTS workflow_runs;
+
+DELETE FROM sync_status
+WHERE entity != 'pull_requests';
+
+ALTER TABLE sync_status
+DROP CONSTRAINT sync_status_pkey,
+DROP COLUMN entity,
+ADD PRIMARY KEY (host, repo);
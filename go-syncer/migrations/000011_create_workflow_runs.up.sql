@@ -0,0 +1,50 @@
+-- 000011_create_workflow_runs.up.sql
+ALTER TABLE sync_status
+ADD COLUMN entity TEXT NOT NULL DEFAULT 'pull_requests',
+DROP CONSTRAINT sync_status_pkey,
+ADD PRIMARY KEY (host, repo, entity);
+
+CREATE TABLE
+  workflow_runs (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    run_id INT8,
+    repo TEXT,
+    workflow_id INT8,
+    name TEXT,
+    event TEXT,
+    status TEXT,
+    conclusion TEXT,
+    head_branch TEXT,
+    head_sha TEXT,
+    run_number INT,
+    run_attempt INT,
+    actor TEXT,
+    pr_ids INT8[],
+    pr_numbers INT[],
+    created_at TIMESTAMP,
+    run_started_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, run_id)
+  );
+
+CREATE TABLE
+  workflow_jobs (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    job_id INT8,
+    run_id INT8,
+    run_attempt INT,
+    repo TEXT,
+    name TEXT,
+    status TEXT,
+    conclusion TEXT,
+    head_sha TEXT,
+    runner_name TEXT,
+    created_at TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, job_id)
+  );
@@ -0,0 +1,13 @@
+-- 000012_create_pull_request_checks.down.sql
+ALTER TABLE pull_request_cycle_times
+DROP COLUMN ci_time_seconds;
+
+ALTER TABLE pull_requests
+DROP COLUMN head_sha,
+DROP COLUMN ci_state,
+DROP COLUMN ci_started_at,
+DROP COLUMN ci_completed_at;
+
+DROP TABLE IF EXISTS pull_request_commit_statuses;
+
+DROP TABLE IF EXISTS pull_request_check_runs;
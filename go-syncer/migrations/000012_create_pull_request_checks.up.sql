@@ -0,0 +1,46 @@
+-- 000012_create_pull_request_checks.up.sql
+CREATE TABLE
+  pull_request_check_runs (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    check_run_id INT8,
+    pr_id INT8,
+    repo TEXT,
+    head_sha TEXT,
+    name TEXT,
+    app TEXT,
+    status TEXT,
+    conclusion TEXT,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, pr_id, check_run_id)
+  );
+
+CREATE TABLE
+  pull_request_commit_statuses (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    status_id INT8,
+    pr_id INT8,
+    repo TEXT,
+    head_sha TEXT,
+    context TEXT,
+    state TEXT,
+    description TEXT,
+    target_url TEXT,
+    creator TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, pr_id, status_id)
+  );
+
+ALTER TABLE pull_requests
+ADD COLUMN head_sha TEXT,
+ADD COLUMN ci_state TEXT,
+ADD COLUMN ci_started_at TIMESTAMP,
+ADD COLUMN ci_completed_at TIMESTAMP;
+
+ALTER TABLE pull_request_cycle_times
+ADD COLUMN ci_time_seconds INT8;
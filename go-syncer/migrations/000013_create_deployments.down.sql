@@ -0,0 +1,13 @@
+-- 000013_create_deployments.down.sql
+DROP TABLE IF EXISTS dora_daily_metrics;
+
+DROP TABLE IF EXISTS deployment_pull_requests;
+
+DROP TABLE IF EXISTS deployment_commits;
+
+DROP TABLE IF EXISTS deployment_statuses;
+
+DROP TABLE IF EXISTS deployments;
+
+ALTER TABLE pull_requests
+DROP COLUMN merge_commit_sha;
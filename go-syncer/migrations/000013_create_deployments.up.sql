@@ -0,0 +1,77 @@
+-- 000013_create_deployments.up.sql
+ALTER TABLE pull_requests
+ADD COLUMN merge_commit_sha TEXT;
+
+CREATE TABLE
+  deployments (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    deployment_id INT8,
+    repo TEXT,
+    environment TEXT,
+    sha TEXT,
+    ref TEXT,
+    task TEXT,
+    creator TEXT,
+    state TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    succeeded_at TIMESTAMP,
+    failed_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, deployment_id)
+  );
+
+CREATE TABLE
+  deployment_statuses (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    status_id INT8,
+    deployment_id INT8,
+    repo TEXT,
+    state TEXT,
+    description TEXT,
+    creator TEXT,
+    created_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, status_id)
+  );
+
+CREATE TABLE
+  deployment_commits (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    deployment_id INT8,
+    repo TEXT,
+    sha TEXT,
+    UNIQUE (host, deployment_id, sha)
+  );
+
+CREATE TABLE
+  deployment_pull_requests (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    deployment_id INT8,
+    pr_id INT8,
+    repo TEXT,
+    environment TEXT,
+    deployed_at TIMESTAMP,
+    UNIQUE (host, deployment_id, pr_id)
+  );
+
+CREATE TABLE
+  dora_daily_metrics (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    repo TEXT,
+    environment TEXT,
+    day DATE,
+    deployments INT,
+    failed_deployments INT,
+    change_failure_rate DOUBLE PRECISION,
+    lead_time_seconds_median INT8,
+    restores INT,
+    time_to_restore_seconds_avg INT8,
+    computed_at TIMESTAMP,
+    UNIQUE (host, repo, environment, day)
+  );
@@ -0,0 +1,10 @@
+-- 000014_create_releases.down.sql
+ALTER TABLE pull_requests
+DROP COLUMN release_tag,
+DROP COLUMN released_at;
+
+DROP TABLE IF EXISTS tag_commits;
+
+DROP TABLE IF EXISTS tags;
+
+DROP TABLE IF EXISTS releases;
@@ -0,0 +1,43 @@
+-- 000014_create_releases.up.sql
+CREATE TABLE
+  releases (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    release_id INT8,
+    repo TEXT,
+    tag_name TEXT,
+    name TEXT,
+    draft BOOLEAN,
+    prerelease BOOLEAN,
+    target_commitish TEXT,
+    author TEXT,
+    created_at TIMESTAMP,
+    published_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, release_id)
+  );
+
+CREATE TABLE
+  tags (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    repo TEXT,
+    name TEXT,
+    sha TEXT,
+    tagged_at TIMESTAMP,
+    UNIQUE (host, repo, name)
+  );
+
+CREATE TABLE
+  tag_commits (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    repo TEXT,
+    tag TEXT,
+    sha TEXT,
+    UNIQUE (host, repo, tag, sha)
+  );
+
+ALTER TABLE pull_requests
+ADD COLUMN release_tag TEXT,
+ADD COLUMN released_at TIMESTAMP;
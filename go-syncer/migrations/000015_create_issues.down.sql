@@ -0,0 +1,4 @@
+-- 000015_create_issues.down.sql
+DROP TABLE IF EXISTS pull_request_issues;
+
+DROP TABLE IF EXISTS issues;
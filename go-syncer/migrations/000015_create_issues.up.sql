@@ -0,0 +1,37 @@
+-- 000015_create_issues.up.sql
+CREATE TABLE
+  issues (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    issue_id INT8,
+    repo TEXT,
+    number INT,
+    title TEXT,
+    body TEXT,
+    state TEXT,
+    state_reason TEXT,
+    username TEXT,
+    labels TEXT[],
+    assignees TEXT[],
+    milestone TEXT,
+    comments INT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    closed_at TIMESTAMP,
+    data JSON,
+    UNIQUE (host, issue_id)
+  );
+
+CREATE INDEX issues_host_repo_number_idx ON issues (host, repo, number);
+
+CREATE TABLE
+  pull_request_issues (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    pr_id INT8,
+    repo TEXT,
+    issue_repo TEXT,
+    issue_number INT,
+    keyword TEXT,
+    UNIQUE (host, pr_id, issue_repo, issue_number)
+  );
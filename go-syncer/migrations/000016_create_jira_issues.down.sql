@@ -0,0 +1,2 @@
+-- 000016_create_jira_issues.down.sql
+-- jira_issues and its index are shared with the Python syncer, which created them first, so they're kept
@@ -0,0 +1,22 @@
+-- 000016_create_jira_issues.up.sql
+-- The table may already exist when migrating from the Python syncer
+CREATE TABLE IF NOT EXISTS
+  jira_issues (
+    id INT8 PRIMARY KEY,
+    key TEXT NOT NULL,
+    issue_type TEXT NOT NULL,
+    project_key TEXT NOT NULL,
+    status TEXT NOT NULL,
+    resolution TEXT,
+    resolution_date TIMESTAMP,
+    summary TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    updated TIMESTAMP NOT NULL,
+    priority TEXT NOT NULL,
+    labels TEXT[],
+    assignee_email TEXT,
+    reporter_email TEXT NOT NULL,
+    sprint_name TEXT
+  );
+
+CREATE INDEX IF NOT EXISTS idx_jira_issues_key ON jira_issues (key);
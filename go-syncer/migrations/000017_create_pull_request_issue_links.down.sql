@@ -0,0 +1,5 @@
+-- 000017_create_pull_request_issue_links.down.sql
+DROP TABLE IF EXISTS pull_request_issue_links;
+
+ALTER TABLE pull_requests
+DROP COLUMN head_ref;
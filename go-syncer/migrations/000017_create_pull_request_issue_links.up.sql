@@ -0,0 +1,19 @@
+-- 000017_create_pull_request_issue_links.up.sql
+ALTER TABLE pull_requests
+ADD COLUMN head_ref TEXT;
+
+UPDATE pull_requests
+SET head_ref = data -> 'head' ->> 'ref';
+
+CREATE TABLE
+  pull_request_issue_links (
+    id SERIAL PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT 'github.com',
+    pr_id INT8,
+    repo TEXT,
+    issue_key TEXT,
+    field TEXT,
+    UNIQUE (host, pr_id, issue_key, field)
+  );
+
+CREATE INDEX pull_request_issue_links_issue_key_idx ON pull_request_issue_links (issue_key);
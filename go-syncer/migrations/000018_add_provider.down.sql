@@ -0,0 +1,9 @@
+-- 000018_add_provider.down.sql
+ALTER TABLE pull_request_comments
+DROP COLUMN provider;
+
+ALTER TABLE pull_request_reviews
+DROP COLUMN provider;
+
+ALTER TABLE pull_requests
+DROP COLUMN provider;
@@ -0,0 +1,9 @@
+-- 000018_add_provider.up.sql
+ALTER TABLE pull_requests
+ADD COLUMN provider TEXT NOT NULL DEFAULT 'github';
+
+ALTER TABLE pull_request_reviews
+ADD COLUMN provider TEXT NOT NULL DEFAULT 'github';
+
+ALTER TABLE pull_request_comments
+ADD COLUMN provider TEXT NOT NULL DEFAULT 'github';
@@ -0,0 +1,3 @@
+-- 000019_add_provider_to_pull_request_events.down.sql
+ALTER TABLE pull_request_events
+DROP COLUMN provider;
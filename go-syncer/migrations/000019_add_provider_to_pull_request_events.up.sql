@@ -0,0 +1,3 @@
+-- 000019_add_provider_to_pull_request_events.up.sql
+ALTER TABLE pull_request_events
+ADD COLUMN provider TEXT NOT NULL DEFAULT 'github';
@@ -0,0 +1,4 @@
+-- 000020_create_sync_runs.down.sql
+DROP TABLE IF EXISTS sync_run_repos;
+
+DROP TABLE IF EXISTS sync_runs;
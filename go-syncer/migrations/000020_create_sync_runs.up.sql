@@ -0,0 +1,34 @@
+-- 000020_create_sync_runs.up.sql
+CREATE TABLE
+  sync_runs (
+    id SERIAL PRIMARY KEY,
+    source TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    prs_upserted INT NOT NULL DEFAULT 0,
+    reviews_upserted INT NOT NULL DEFAULT 0,
+    api_calls INT NOT NULL DEFAULT 0,
+    error TEXT
+  );
+
+CREATE INDEX sync_runs_source_started_at_idx ON sync_runs (source, started_at);
+
+CREATE TABLE
+  sync_run_repos (
+    id SERIAL PRIMARY KEY,
+    run_id INT NOT NULL REFERENCES sync_runs (id) ON DELETE CASCADE,
+    host TEXT NOT NULL,
+    repo TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    prs_upserted INT NOT NULL DEFAULT 0,
+    reviews_upserted INT NOT NULL DEFAULT 0,
+    api_calls INT NOT NULL DEFAULT 0,
+    error TEXT
+  );
+
+CREATE INDEX sync_run_repos_run_id_idx ON sync_run_repos (run_id);
+
+CREATE INDEX sync_run_repos_host_repo_started_at_idx ON sync_run_repos (host, repo, started_at);
@@ -0,0 +1,4 @@
+-- 000021_create_jobs.down.sql
+DROP VIEW IF EXISTS job_queue;
+
+DROP TABLE IF EXISTS jobs;
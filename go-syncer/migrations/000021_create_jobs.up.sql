@@ -0,0 +1,48 @@
+-- 000021_create_jobs.up.sql
+CREATE TABLE
+  jobs (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    dedup_key TEXT,
+    status TEXT NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_by TEXT,
+    locked_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+  );
+
+-- A job isn't queued again while the same job is queued or running
+CREATE UNIQUE INDEX jobs_dedup_key_idx ON jobs (dedup_key)
+WHERE
+  status IN ('queued', 'running');
+
+CREATE INDEX jobs_queued_run_at_idx ON jobs (run_at)
+WHERE
+  status = 'queued';
+
+CREATE INDEX jobs_running_locked_at_idx ON jobs (locked_at)
+WHERE
+  status = 'running';
+
+CREATE VIEW
+  job_queue AS
+SELECT
+  kind,
+  status,
+  COUNT(*) AS jobs,
+  MIN(run_at) AS next_run_at,
+  MIN(created_at) AS oldest_created_at,
+  MAX(attempts) AS max_attempts
+FROM
+  jobs
+WHERE
+  status IN ('queued', 'running', 'dead')
+GROUP BY
+  kind,
+  status;
@@ -0,0 +1,7 @@
+-- 000022_create_pull_request_issue_link_runs.down.sql
+DROP TABLE IF EXISTS pull_request_issue_link_runs;
+
+DROP INDEX IF EXISTS pull_requests_synced_at_idx;
+
+ALTER TABLE pull_requests
+DROP COLUMN synced_at;
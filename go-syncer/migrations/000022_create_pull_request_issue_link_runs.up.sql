@@ -0,0 +1,13 @@
+-- 000022_create_pull_request_issue_link_runs.up.sql
+-- When a pull request was last upserted by a sync, so linking only matches the pull requests synced since its last run
+ALTER TABLE pull_requests
+ADD COLUMN synced_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX pull_requests_synced_at_idx ON pull_requests (synced_at);
+
+CREATE TABLE
+  pull_request_issue_link_runs (
+    source TEXT PRIMARY KEY,
+    patterns TEXT[] NOT NULL,
+    linked_at TIMESTAMP NOT NULL
+  );
@@ -0,0 +1,2 @@
+-- 000023_create_jobs_run_id_index.down.sql
+DROP INDEX IF EXISTS jobs_run_id_idx;
@@ -0,0 +1,3 @@
+-- 000023_create_jobs_run_id_index.up.sql
+-- Sync runs waiting for their jobs look them up by the run ID in their payload
+CREATE INDEX jobs_run_id_idx ON jobs ((payload ->> 'run_id'));
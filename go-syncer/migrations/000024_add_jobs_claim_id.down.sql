@@ -0,0 +1,3 @@
+-- 000024_add_jobs_claim_id.down.sql
+ALTER TABLE jobs
+DROP COLUMN IF EXISTS claim_id;
@@ -0,0 +1,4 @@
+-- 000024_add_jobs_claim_id.up.sql
+-- Every claim of a job gets its own ID, so a worker only records the outcome of its own claim
+ALTER TABLE jobs
+ADD COLUMN claim_id UUID;
@@ -0,0 +1,4 @@
+-- 000025_create_sync_run_jobs.down.sql
+CREATE INDEX IF NOT EXISTS jobs_run_id_idx ON jobs ((payload ->> 'run_id'));
+
+DROP TABLE IF EXISTS sync_run_jobs;
@@ -0,0 +1,21 @@
+-- 000025_create_sync_run_jobs.up.sql
+-- The jobs a sync run queued, or found already queued, and waits for
+CREATE TABLE
+  sync_run_jobs (
+    run_id INT NOT NULL REFERENCES sync_runs (id) ON DELETE CASCADE,
+    job_id INT NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    PRIMARY KEY (run_id, job_id)
+  );
+
+CREATE INDEX sync_run_jobs_job_id_idx ON sync_run_jobs (job_id);
+
+INSERT INTO
+  sync_run_jobs (run_id, job_id)
+SELECT
+  r.id,
+  j.id
+FROM
+  jobs j
+  JOIN sync_runs r ON r.id::TEXT = j.payload ->> 'run_id';
+
+DROP INDEX IF EXISTS jobs_run_id_idx;